@@ -2,36 +2,182 @@ package httpsignatures
 
 import (
 	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+
+	ed25519 "github.com/agl/ed25519"
 )
 
 var (
-	AlgorithmHmacSha1   = "hmac-sha1"
-	AlgorithmHmacSha256 = "hmac-sha256"
-	AlgorithmEd25519    = "ed25519"
+	AlgorithmHmacSha1     = "hmac-sha1"
+	AlgorithmHmacSha256   = "hmac-sha256"
+	AlgorithmEd25519      = "ed25519"
+	AlgorithmRsaSha256    = "rsa-sha256"
+	AlgorithmRsaPssSha512 = "rsa-pss-sha512"
+	AlgorithmHs2019       = "hs2019"
+
+	algorithmHmacSha1     = &Algorithm{"hmac-sha1", AlgorithmKindHMAC, Hmac1Sign, Hmac1Verify}
+	algorithmHmacSha256   = &Algorithm{"hmac-sha256", AlgorithmKindHMAC, Hmac256Sign, Hmac256Verify}
+	algorithmEd25519      = &Algorithm{"ed25519", AlgorithmKindEd25519, Ed25519Sign, Ed25519Verify}
+	algorithmRsaSha256    = &Algorithm{"rsa-sha256", AlgorithmKindRSAPKCS1v15, RsaSha256Sign, RsaSha256Verify}
+	algorithmRsaPssSha512 = &Algorithm{"rsa-pss-sha512", AlgorithmKindRSAPSS, RsaPssSha512Sign, RsaPssSha512Verify}
+	algorithmHs2019       = &Algorithm{"hs2019", AlgorithmKindHs2019, Hs2019Sign, Hs2019Verify}
+
+	errorUnknownAlgorithm = errors.New(ErrorUnknownAlgorithm)
+
+	registeredAlgorithmsMu sync.RWMutex
+	registeredAlgorithms   = map[string]*Algorithm{}
+	registeredHashSizes    = map[string]int{}
+)
 
-	algorithmHmacSha1   = &Algorithm{"hmac-sha1", Hmac1Sign, Hmac1Verify}
-	algorithmHmacSha256 = &Algorithm{"hmac-sha256", Hmac256Sign, Hmac256Verify}
-	algorithmEd25519    = &Algorithm{"ed25519", Ed25519Sign, Ed25519Verify}
+// AlgorithmKind is the signature scheme used by an algorithm
+type AlgorithmKind int
 
-	errorUnknownAlgorithm = errors.New("Unknown signature algorithm provided")
+const (
+	AlgorithmKindHMAC AlgorithmKind = iota
+	AlgorithmKindEd25519
+	AlgorithmKindRSAPKCS1v15
+	AlgorithmKindRSAPSS
+	// AlgorithmKindHs2019 derives the signature scheme from the key
+	AlgorithmKindHs2019
 )
 
-// Algorithm exports the main algorithm properties: name, sign, verify
+// Algorithm exports the main algorithm properties: name, kind, sign, verify
 type Algorithm struct {
 	Name   string
+	Kind   AlgorithmKind
 	Sign   func(privateKey *[]byte, message []byte) (*[]byte, error)
 	Verify func(key *[]byte, message []byte, signature *[]byte) (bool, error)
 }
 
+// algorithmFromString looks up the algorithm by its case insensitive name
 func algorithmFromString(name string) (*Algorithm, error) {
+	name = strings.ToLower(name)
+	if alg := builtinAlgorithm(name); alg != nil {
+		return alg, nil
+	}
+
+	registeredAlgorithmsMu.RLock()
+	defer registeredAlgorithmsMu.RUnlock()
+	if alg, ok := registeredAlgorithms[name]; ok {
+		return alg, nil
+	}
+	return nil, errorUnknownAlgorithm
+}
+
+// checkSigningKey checks that the decoded private key is of the kind the
+// algorithm signs with, so a wrong key gives a clear error instead of an
+// invalid signature. HMAC accepts any secret, except a RSA private key.
+func checkSigningKey(alg *Algorithm, key []byte) error {
+	var ok bool
+	switch alg.Kind {
+	case AlgorithmKindHMAC:
+		_, err := parseRSAPrivateKey(key)
+		ok = err != nil
+	case AlgorithmKindEd25519:
+		ok = len(key) == ed25519.PrivateKeySize
+	case AlgorithmKindRSAPKCS1v15, AlgorithmKindRSAPSS:
+		_, err := parseRSAPrivateKey(key)
+		ok = err == nil
+	default:
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("%s: '%s'", ErrorKeyAlgorithmMismatch, alg.Name)
+	}
+	return nil
+}
+
+// checkVerifyingKey rejects RSA keys for HMAC algorithms, so a client can not
+// sign with HMAC using the public RSA key of the server as the secret
+func checkVerifyingKey(alg *Algorithm, key []byte) error {
+	if alg.Kind != AlgorithmKindHMAC {
+		return nil
+	}
+	_, publicErr := parseRSAPublicKey(key)
+	_, privateErr := parseRSAPrivateKey(key)
+	if publicErr == nil || privateErr == nil {
+		return fmt.Errorf("%s: '%s'", ErrorAlgorithmNotAllowedForKey, alg.Name)
+	}
+	return nil
+}
+
+func builtinAlgorithm(name string) *Algorithm {
 	switch name {
 	case AlgorithmHmacSha1:
-		return algorithmHmacSha1, nil
+		return algorithmHmacSha1
 	case AlgorithmHmacSha256:
-		return algorithmHmacSha256, nil
+		return algorithmHmacSha256
 	case AlgorithmEd25519:
-		return algorithmEd25519, nil
+		return algorithmEd25519
+	case AlgorithmRsaSha256:
+		return algorithmRsaSha256
+	case AlgorithmRsaPssSha512:
+		return algorithmRsaPssSha512
+	case AlgorithmHs2019:
+		return algorithmHs2019
+	}
+	return nil
+}
+
+// SupportedAlgorithms returns the names of the built-in algorithms followed by
+// the names of the registered algorithms, sorted
+func SupportedAlgorithms() []string {
+	names := []string{AlgorithmHmacSha1, AlgorithmHmacSha256, AlgorithmEd25519, AlgorithmRsaSha256,
+		AlgorithmRsaPssSha512, AlgorithmHs2019}
+
+	registeredAlgorithmsMu.RLock()
+	registered := make([]string, 0, len(registeredAlgorithms))
+	for name := range registeredAlgorithms {
+		registered = append(registered, name)
 	}
+	registeredAlgorithmsMu.RUnlock()
 
-	return nil, errorUnknownAlgorithm
+	sort.Strings(registered)
+	return append(names, registered...)
+}
+
+// RegisterAlgorithm adds a custom algorithm, which can then be used by its case
+// insensitive name to sign and verify like the built-in algorithms. Only
+// AlgorithmKindHMAC is supported, using newHash as the hash function.
+// Registering a name again replaces the algorithm, the built-in algorithms can
+// not be replaced. It is safe for concurrent use, but is meant to be called at
+// init time.
+func RegisterAlgorithm(name string, newHash func() hash.Hash, kind AlgorithmKind) error {
+	if kind != AlgorithmKindHMAC {
+		return errors.New(ErrorUnsupportedAlgorithmKind)
+	}
+	name = strings.ToLower(name)
+	if builtinAlgorithm(name) != nil {
+		return fmt.Errorf("%s '%s'", ErrorBuiltinAlgorithm, name)
+	}
+
+	size := newHash().Size()
+	registeredAlgorithmsMu.Lock()
+	defer registeredAlgorithmsMu.Unlock()
+	registeredAlgorithms[name] = &Algorithm{
+		Name: name,
+		Kind: kind,
+		Sign: func(privateKey *[]byte, message []byte) (*[]byte, error) {
+			return Sign(privateKey, message, newHash, size)
+		},
+		Verify: func(key *[]byte, message []byte, signature *[]byte) (bool, error) {
+			return Verify(key, message, newHash, signature, size)
+		},
+	}
+	registeredHashSizes[name] = size
+	return nil
+}
+
+// registeredAlgorithm returns the registered algorithm and the size in bytes
+// of its hash
+func registeredAlgorithm(name string) (*Algorithm, int, bool) {
+	name = strings.ToLower(name)
+	registeredAlgorithmsMu.RLock()
+	defer registeredAlgorithmsMu.RUnlock()
+	alg, ok := registeredAlgorithms[name]
+	return alg, registeredHashSizes[name], ok
 }
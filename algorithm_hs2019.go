@@ -0,0 +1,64 @@
+package httpsignatures
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+)
+
+// Hs2019Sign signs the message with the algorithm derived from the private key
+func Hs2019Sign(privateKey *[]byte, message []byte) (*[]byte, error) {
+	alg, err := algorithmFromKey(*privateKey, true)
+	if err != nil {
+		return nil, err
+	}
+	return alg.Sign(privateKey, message)
+}
+
+// Hs2019Verify verifies the message with the algorithm derived from the public key
+func Hs2019Verify(publicKey *[]byte, message []byte, signature *[]byte) (bool, error) {
+	alg, err := algorithmFromKey(*publicKey, false)
+	if err != nil {
+		return false, err
+	}
+	return alg.Verify(publicKey, message, signature)
+}
+
+// algorithmFromKey derives the algorithm for hs2019 from the key material:
+// DER encoded RSA keys use rsa-pss-sha512 and raw Ed25519 keys, as used by
+// Ed25519Sign and Ed25519Verify, use ed25519. A private Ed25519 key must hold
+// the public key derived from its seed. hs2019 does not support HMAC, as
+// symmetric keys can not be told apart from other keys: HMAC secrets fail with
+// ErrorUnknownKeyType, except for 32 byte secrets used for verification, which
+// are read as Ed25519 public keys and fail to verify.
+func algorithmFromKey(key []byte, private bool) (*Algorithm, error) {
+	if _, err := parseRSAPublicKey(key); err == nil {
+		return algorithmRsaPssSha512, nil
+	}
+	if _, err := parseRSAPrivateKey(key); err == nil {
+		return algorithmRsaPssSha512, nil
+	}
+	if private && len(key) == ed25519.PrivateKeySize &&
+		bytes.Equal(ed25519.NewKeyFromSeed(key[:ed25519.SeedSize]), key) {
+		return algorithmEd25519, nil
+	}
+	if !private && len(key) == ed25519.PublicKeySize {
+		return algorithmEd25519, nil
+	}
+	return nil, errors.New(ErrorUnknownKeyType)
+}
+
+// resolveAlgorithm replaces the hs2019 algorithm of the signature with the
+// algorithm derived from the base64 encoded key
+func (s *SignatureParameters) resolveAlgorithm(keyBase64 string) error {
+	byteKey, err := decodeKey(keyBase64)
+	if err != nil {
+		return err
+	}
+	alg, err := algorithmFromKey(byteKey, false)
+	if err != nil {
+		return err
+	}
+	s.Algorithm = alg
+	return nil
+}
@@ -0,0 +1,95 @@
+package httpsignatures
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"errors"
+)
+
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+
+// RsaSha256Sign signs the message with RSASSA-PKCS1-v1_5 and SHA-256 using the
+// DER encoded (PKCS#1 or PKCS#8) private key
+func RsaSha256Sign(privateKey *[]byte, message []byte) (*[]byte, error) {
+	key, err := parseRSAPrivateKey(*privateKey)
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha256.Sum256(message)
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return nil, err
+	}
+	return &sig, nil
+}
+
+// RsaSha256Verify verifies the RSASSA-PKCS1-v1_5 SHA-256 signature of the message
+// using the DER encoded (PKIX or PKCS#1) public key
+func RsaSha256Verify(publicKey *[]byte, message []byte, signature *[]byte) (bool, error) {
+	key, err := parseRSAPublicKey(*publicKey)
+	if err != nil {
+		return false, err
+	}
+	hashed := sha256.Sum256(message)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], *signature); err != nil {
+		return false, errors.New(ErrorSignaturesDoNotMatch)
+	}
+	return true, nil
+}
+
+// RsaPssSha512Sign signs the message with RSASSA-PSS and SHA-512, with a salt
+// length equal to the hash length, using the DER encoded private key
+func RsaPssSha512Sign(privateKey *[]byte, message []byte) (*[]byte, error) {
+	key, err := parseRSAPrivateKey(*privateKey)
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha512.Sum512(message)
+	sig, err := rsa.SignPSS(rand.Reader, key, crypto.SHA512, hashed[:], pssOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &sig, nil
+}
+
+// RsaPssSha512Verify verifies the RSASSA-PSS SHA-512 signature of the message
+// using the DER encoded public key
+func RsaPssSha512Verify(publicKey *[]byte, message []byte, signature *[]byte) (bool, error) {
+	key, err := parseRSAPublicKey(*publicKey)
+	if err != nil {
+		return false, err
+	}
+	hashed := sha512.Sum512(message)
+	if err := rsa.VerifyPSS(key, crypto.SHA512, hashed[:], *signature, pssOptions); err != nil {
+		return false, errors.New(ErrorSignaturesDoNotMatch)
+	}
+	return true, nil
+}
+
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+	}
+	return nil, errors.New(ErrorInvalidRSAKey)
+}
+
+func parseRSAPublicKey(der []byte) (*rsa.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New(ErrorInvalidRSAKey)
+}
@@ -1,6 +1,9 @@
 package httpsignatures
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -67,3 +70,59 @@ func TestAllAlgorithms(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+func TestRSAAlgorithms(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := x509.MarshalPKCS1PrivateKey(key)
+	pubKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+
+	for _, a := range []string{"rsa-sha256", "rsa-pss-sha512"} {
+		algorithm, err := algorithmFromString(a)
+		assert.Nil(t, err)
+
+		signature, err := algorithm.Sign(&privKey, ([]byte)(plainText))
+		assert.Nil(t, err)
+		assert.Len(t, *signature, 256)
+
+		valid, err := algorithm.Verify(&pubKey, ([]byte)(plainText), signature)
+		assert.True(t, valid)
+		assert.Nil(t, err)
+
+		valid, err = algorithm.Verify(&pubKey, ([]byte)(plainText+"!"), signature)
+		assert.False(t, valid)
+		assert.EqualError(t, err, ErrorSignaturesDoNotMatch)
+	}
+}
+
+func TestRSAPKCS1v15AndPSSAreDistinct(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	pubKey := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+
+	assert.Equal(t, AlgorithmKindRSAPKCS1v15, algorithmRsaSha256.Kind)
+	assert.Equal(t, AlgorithmKindRSAPSS, algorithmRsaPssSha512.Kind)
+
+	signature, err := algorithmRsaSha256.Sign(&privKey, ([]byte)(plainText))
+	assert.Nil(t, err)
+	valid, err := algorithmRsaPssSha512.Verify(&pubKey, ([]byte)(plainText), signature)
+	assert.False(t, valid)
+	assert.EqualError(t, err, ErrorSignaturesDoNotMatch)
+
+	signature, err = algorithmRsaPssSha512.Sign(&privKey, ([]byte)(plainText))
+	assert.Nil(t, err)
+	valid, err = algorithmRsaSha256.Verify(&pubKey, ([]byte)(plainText), signature)
+	assert.False(t, valid)
+	assert.EqualError(t, err, ErrorSignaturesDoNotMatch)
+}
+
+func TestRSAInvalidKey(t *testing.T) {
+	key := []byte("not a key")
+	_, err := algorithmRsaPssSha512.Sign(&key, ([]byte)(plainText))
+	assert.EqualError(t, err, ErrorInvalidRSAKey)
+	_, err = algorithmRsaPssSha512.Verify(&key, ([]byte)(plainText), &key)
+	assert.EqualError(t, err, ErrorInvalidRSAKey)
+}
@@ -0,0 +1,49 @@
+package httpsignatures
+
+import (
+	"net/http"
+)
+
+// RequestSigner signs requests, as the signers of NewSigner and NewMultiSigner do
+type RequestSigner interface {
+	SignRequest(r *http.Request, keyID string, keyB64 string) error
+}
+
+// SignedClient is a http.Client which signs the requests it sends with Do.
+// The other methods of the embedded client, such as Get and Post, do not
+// sign the requests.
+type SignedClient struct {
+	// Client sends the requests, http.DefaultClient when nil
+	*http.Client
+	Signer RequestSigner
+	KeyID  string
+	KeyB64 string
+	// VerifyResponse optionally checks the responses, eg their status or
+	// headers, a response which does not pass is closed and its error returned
+	// by Do. This package does not support response signatures, so there is no
+	// verification of a signed response to pass here.
+	VerifyResponse func(res *http.Response) error
+}
+
+// Do signs the request with the Signature: HTTP Header and sends it
+func (c SignedClient) Do(r *http.Request) (*http.Response, error) {
+	if err := c.Signer.SignRequest(r, c.KeyID, c.KeyB64); err != nil {
+		return nil, err
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if c.VerifyResponse != nil {
+		if err := c.VerifyResponse(res); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+	}
+	return res, nil
+}
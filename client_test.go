@@ -0,0 +1,92 @@
+package httpsignatures_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func TestSignedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+			httpsignatures.HeaderRequestTarget, httpsignatures.HeaderHost)
+		if err != nil {
+			httpErr, msg := httpsignatures.ErrorToHTTPCode(err.Error())
+			http.Error(w, msg, httpErr)
+			return
+		}
+		w.Header().Set("X-Verified-Path", r.URL.Path)
+		w.Write([]byte("verified"))
+	}))
+	defer server.Close()
+
+	client := httpsignatures.SignedClient{
+		Signer: httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date"),
+		KeyID:  testKeyID,
+		KeyB64: testKey,
+		VerifyResponse: func(res *http.Response) error {
+			if res.StatusCode != http.StatusOK || res.Header.Get("X-Verified-Path") != res.Request.URL.Path {
+				return errors.New("unexpected response")
+			}
+			return nil
+		},
+	}
+
+	r, err := http.NewRequest(http.MethodGet, server.URL+"/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	res, err := client.Do(r)
+	assert.Nil(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "verified", string(body))
+
+	// the response to a request signed with another key fails the verification
+	r, err = http.NewRequest(http.MethodGet, server.URL+"/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	client.KeyB64 = base64.StdEncoding.EncodeToString([]byte("another key"))
+	client.Client = server.Client()
+	res, err = client.Do(r)
+	assert.Nil(t, res)
+	assert.EqualError(t, err, "unexpected response")
+
+	// signing errors are returned before sending
+	client.KeyID = ""
+	_, err = client.Do(r)
+	assert.EqualError(t, err, httpsignatures.ErrorNoKeyIDConfigured)
+}
+
+// keySourceSigner signs with the key of the keyId it is configured with,
+// ignoring the key passed by the client
+type keySourceSigner map[string]string
+
+func (s keySourceSigner) SignRequest(r *http.Request, keyID string, keyB64 string) error {
+	return DefaultSha256Signer.SignRequest(r, keyID, s[keyID])
+}
+
+func TestSignedClientWithRequestSigner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client := httpsignatures.SignedClient{Signer: keySourceSigner{testKeyID: testKey}, KeyID: testKeyID}
+	r, err := http.NewRequest(http.MethodGet, server.URL+"/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	res, err := client.Do(r)
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,140 @@
+package httpsignatures
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DiffSigningStrings builds the signing strings of both requests for the
+// given headers and returns a line based diff of them. Equal lines are
+// prefixed with two spaces, differing lines with "- " for request a and
+// "+ " for request b. The signing strings are built with the options of the
+// signer, eg WithSigningStringLineEnding.
+func DiffSigningStrings(a, b *http.Request, headers []string, opts ...Option) (string, error) {
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	o := newOptions(opts)
+
+	linesA, err := signingStringLines(a, headers, o)
+	if err != nil {
+		return "", err
+	}
+	linesB, err := signingStringLines(b, headers, o)
+	if err != nil {
+		return "", err
+	}
+
+	var diff []string
+	for i := range linesA {
+		if linesA[i] == linesB[i] {
+			diff = append(diff, "  "+linesA[i])
+		} else {
+			diff = append(diff, "- "+linesA[i], "+ "+linesB[i])
+		}
+	}
+	return strings.Join(diff, "\n"), nil
+}
+
+// DiagnoseRequest runs all checks of VerifyRequest on the request and returns
+// every problem found instead of stopping at the first one: missing headers,
+// a disallowed algorithm, missing required headers, the clock skew and a
+// signature mismatch. The options are those of VerifyRequestWithOptions, so
+// the request is diagnosed as it is verified. It is meant for debugging
+// client integrations, use VerifyRequestWithOptions to verify requests.
+func DiagnoseRequest(r *http.Request, keyLookUp func(keyID string) (string, error), allowedClockSkew int,
+	allowedAlgorithms []string, requiredHeaders []string, opts ...Option) []error {
+	o := newOptions(opts)
+
+	var sig SignatureParameters
+	if o.rfc9421 {
+		if err := sig.parseSignatureInput(r, o); err != nil {
+			return []error{err}
+		}
+	} else {
+		httpSignatureString, err := signatureStringFromRequest(r, o)
+		if err != nil {
+			return []error{err}
+		}
+		if err := sig.parseSignatureString(httpSignatureString, o); err != nil {
+			return []error{err}
+		}
+		sig.defaultHeaders(r)
+	}
+
+	var problems []error
+	sig.Headers = HeaderValues{}
+	for _, header := range sig.HeaderList {
+		value, err := sig.signedValue(r, header, o)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		sig.Headers[header] = value
+	}
+	headersComplete := len(problems) == 0
+	if host := o.trustedHost(r); host != "" && sig.hasHeader(HeaderHost) {
+		sig.Headers[HeaderHost] = host
+	}
+	if o.keyIDHeader != "" {
+		if !sig.hasHeader(o.keyIDHeader) {
+			problems = append(problems, fmt.Errorf("%s: '%s'", ErrorKeyIDHeaderNotSigned, o.keyIDHeader))
+		}
+		sig.KeyID = sig.Headers[headerName(o.keyIDHeader)]
+	}
+
+	if len(allowedAlgorithms) == 0 && o.keyAlgorithms != nil {
+		algorithms, err := o.keyAlgorithms(sig.KeyID)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		allowedAlgorithms = algorithms
+	}
+	derived := sig.Algorithm.Name == AlgorithmHs2019
+	if !derived {
+		if err := checkAlgorithmAllowed(sig, allowedAlgorithms); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, header := range requiredHeaders {
+		if err := checkRequiredHeader(sig, header); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := checkClockSkewOptions(sig, allowedClockSkew, o); err != nil {
+		problems = append(problems, err)
+	}
+
+	// the signature can only be checked against a complete signing string
+	if headersComplete {
+		key, err := keyLookUp(sig.KeyID)
+		if err != nil {
+			return append(problems, err)
+		}
+		if derived {
+			if err := sig.resolveAlgorithm(key); err != nil {
+				return append(problems, err)
+			}
+			if err := checkAlgorithmAllowed(sig, allowedAlgorithms); err != nil {
+				problems = append(problems, err)
+			}
+		}
+		if _, err := sig.verify(key, o); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
+
+func signingStringLines(r *http.Request, headers []string, o options) ([]string, error) {
+	sig := SignatureParameters{HeaderList: headers}
+	if err := sig.parseRequest(r, o); err != nil {
+		return nil, err
+	}
+	signingString, err := sig.signingString(o)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(signingString, "\n"), nil
+}
@@ -0,0 +1,124 @@
+package httpsignatures_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func TestDiffSigningStrings(t *testing.T) {
+	a, err := http.NewRequest(http.MethodGet, "https://a.example.com/foo", nil)
+	assert.Nil(t, err)
+	a.Header.Set("Date", testDate)
+	b, err := http.NewRequest(http.MethodGet, "https://b.example.com/foo", nil)
+	assert.Nil(t, err)
+	b.Header.Set("Date", testDate)
+
+	diff, err := httpsignatures.DiffSigningStrings(a, b, []string{"(request-target)", "host", "date"})
+	assert.Nil(t, err)
+	assert.Equal(t, "  (request-target): get /foo\n"+
+		"- host: a.example.com\n"+
+		"+ host: b.example.com\n"+
+		"  date: "+testDate, diff)
+}
+
+func TestDiffSigningStringsMissingHeader(t *testing.T) {
+	a := &http.Request{Header: http.Header{"Date": []string{testDate}}}
+	b := &http.Request{Header: http.Header{}}
+
+	diff, err := httpsignatures.DiffSigningStrings(a, a, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "  date: "+testDate, diff)
+
+	_, err = httpsignatures.DiffSigningStrings(a, b, nil)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" 'date'")
+}
+
+func TestDiagnoseRequestReportsAllProblems(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "x-custom")
+	r.Header.Set("X-Custom", "value")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	r.Header.Del("X-Custom")
+
+	problems := httpsignatures.DiagnoseRequest(r, keyLookUp, 300, []string{httpsignatures.AlgorithmEd25519},
+		[]string{httpsignatures.HeaderRequestTarget})
+	assert.Len(t, problems, 4)
+	assert.EqualError(t, problems[0], httpsignatures.ErrorMissingRequiredHeader+" 'x-custom'")
+	assert.EqualError(t, problems[1], httpsignatures.ErrorAlgorithmNotAllowed)
+	assert.EqualError(t, problems[2], httpsignatures.ErrorRequiredHeaderNotInHeaderList+": '(request-target)'")
+	assert.EqualError(t, problems[3], httpsignatures.ErrorAllowedClockskewExceeded)
+}
+
+func TestDiagnoseRequestReportsSignatureMismatch(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	problems := httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil)
+	assert.Empty(t, problems)
+
+	r.Header.Set("Date", "Thu, 05 Jan 2012 21:31:41 GMT")
+	problems = httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha1}, nil)
+	assert.Len(t, problems, 2)
+	assert.EqualError(t, problems[0], httpsignatures.ErrorAlgorithmNotAllowed)
+	assert.EqualError(t, problems[1], httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestDiagnoseRequestWithOptions(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+	created := time.Unix(1325799100, 0)
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha256,
+		[]string{"@method", "@path"}, httpsignatures.WithRFC9421(), httpsignatures.WithCreated(created))
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// the RFC 9421 signature is only read with the verification options
+	problems := httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil)
+	assert.Len(t, problems, 1)
+	assert.EqualError(t, problems[0], httpsignatures.ErrorMissingSignatureParameterSignature)
+
+	problems = httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil,
+		httpsignatures.WithRFC9421())
+	assert.Empty(t, problems)
+
+	problems = httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil,
+		httpsignatures.WithRFC9421(), httpsignatures.WithClockSkew(time.Minute),
+		httpsignatures.WithFreshnessSource(httpsignatures.FreshnessSourceCreated),
+		httpsignatures.WithClock(func() time.Time { return created.Add(2 * time.Minute) }))
+	assert.Len(t, problems, 1)
+	assert.EqualError(t, problems[0], httpsignatures.ErrorAllowedClockskewExceeded)
+}
+
+func TestDiffSigningStringsWithOptions(t *testing.T) {
+	a := &http.Request{Header: http.Header{"Date": []string{testDate}, "X-Custom": []string{"a"}}}
+	b := &http.Request{Header: http.Header{"Date": []string{testDate}, "X-Custom": []string{"b"}}}
+
+	diff, err := httpsignatures.DiffSigningStrings(a, b, []string{"date", "x-custom"},
+		httpsignatures.WithSigningStringLineEnding("\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "  date: "+testDate+"\r\n"+
+		"- x-custom: a\n"+
+		"+ x-custom: b", diff)
+}
+
+func TestDiagnoseRequestWithoutSignature(t *testing.T) {
+	r := &http.Request{Header: http.Header{}}
+
+	problems := httpsignatures.DiagnoseRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil)
+	assert.Len(t, problems, 1)
+	assert.EqualError(t, problems[0], httpsignatures.ErrorNoSignatureHeaderFoundInRequest)
+}
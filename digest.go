@@ -0,0 +1,143 @@
+package httpsignatures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HeaderDigest is the header carrying the digest of the body, sign it to
+// protect the body of the request
+const HeaderDigest = "digest"
+
+// digestAlgorithms are the supported digest algorithms by their lower case token
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// AddDigest sets the Digest: HTTP Header of the request to the SHA-256 digest
+// of its body. The body is read into memory, so streamed bodies without a
+// Content-Length are sent with the length of the digested body, and the body
+// is replaced so it can still be sent. Call it before signing the request.
+func AddDigest(r *http.Request) error {
+	body, err := readBody(r, 0)
+	if err != nil {
+		return err
+	}
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	sum := sha256.Sum256(body)
+	r.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+	return nil
+}
+
+// ParseDigestHeader parses the value of a Digest: HTTP Header into the base64
+// encoded digests by their algorithm. The algorithm tokens are case
+// insensitive, so they are returned in lower case.
+func ParseDigestHeader(value string) (map[string]string, error) {
+	digests := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		algorithm, digest, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || algorithm == "" || digest == "" {
+			return nil, errors.New(ErrorMalformedDigestHeader)
+		}
+		digests[strings.ToLower(algorithm)] = digest
+	}
+	return digests, nil
+}
+
+// VerifyDigest checks the Digest: HTTP Header of the request against its body.
+// Every supported digest in the header must match, the others are ignored.
+// The body is read into memory and replaced, so it can still be read, see
+// WithMaxDigestBodySize to bound it, WithExpectedDigest to compare with a
+// digest known out of band, or WithSkipDigestOnReEncoding for bodies encoded
+// by a proxy. The digest header is only trusted when it is signed, so call it
+// after verifying a signature which requires HeaderDigest.
+func VerifyDigest(r *http.Request, opts ...Option) error {
+	o := newOptions(opts)
+	value := r.Header.Get("Digest")
+	if value == "" {
+		return fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, HeaderDigest)
+	}
+	digests, err := ParseDigestHeader(value)
+	if err != nil {
+		return err
+	}
+	if o.expectedDigestAlgorithm != "" {
+		if digests[o.expectedDigestAlgorithm] != base64.StdEncoding.EncodeToString(o.expectedDigest) {
+			return errors.New(ErrorDigestMismatch)
+		}
+		return nil
+	}
+	if o.skipDigestOnReEncoding && reEncoded(r) {
+		return nil
+	}
+
+	body, err := readBody(r, o.maxDigestBodySize)
+	if err != nil {
+		return err
+	}
+	verified := false
+	for algorithm, digest := range digests {
+		newHash, ok := digestAlgorithms[algorithm]
+		if !ok {
+			continue
+		}
+		h := newHash()
+		h.Write(body)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != digest {
+			return errors.New(ErrorDigestMismatch)
+		}
+		verified = true
+	}
+	if !verified {
+		return errors.New(ErrorUnsupportedDigestAlgorithm)
+	}
+	return nil
+}
+
+// reEncoded reports whether the body of the request has a content encoding
+// other than identity
+func reEncoded(r *http.Request) bool {
+	encoding := strings.TrimSpace(r.Header.Get("Content-Encoding"))
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// readBody reads the body of the request and replaces it by the read bytes.
+// With a positive limit, bodies larger than limit bytes are not read.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if r.Body != nil && r.Body != http.NoBody {
+		var body io.Reader = r.Body
+		if limit > 0 {
+			// read one byte more to tell a body of limit bytes from a larger one
+			body = io.LimitReader(r.Body, limit+1)
+		}
+		_, err := buf.ReadFrom(body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && int64(buf.Len()) > limit {
+			return nil, errors.New(ErrorBodyTooLargeForDigest)
+		}
+	}
+	body := buf.Bytes()
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
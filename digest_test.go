@@ -0,0 +1,194 @@
+package httpsignatures_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+// chunkedReader hides the length of the body, like a streamed upload
+type chunkedReader struct {
+	r io.Reader
+}
+
+func (c chunkedReader) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func TestAddDigestChunkedBody(t *testing.T) {
+	body := strings.Repeat("chunk of the streamed body\n", 100)
+	sum := sha256.Sum256([]byte(body))
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, body, string(received))
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+		assert.Empty(t, r.TransferEncoding)
+		assert.Equal(t, digest, r.Header.Get("Digest"))
+
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+			httpsignatures.HeaderDigest)
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodPost, server.URL, chunkedReader{strings.NewReader(body)})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), r.ContentLength)
+	r.Header.Set("Date", testDate)
+
+	err = httpsignatures.AddDigest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, digest, r.Header.Get("Digest"))
+
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", httpsignatures.HeaderDigest)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestAddDigestEmptyBody(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+
+	err = httpsignatures.AddDigest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", r.Header.Get("Digest"))
+	assert.Equal(t, int64(0), r.ContentLength)
+}
+
+func TestParseDigestHeader(t *testing.T) {
+	digests, err := httpsignatures.ParseDigestHeader("SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=, sha-512=abc=")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"sha-256": "X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=",
+		"sha-512": "abc=",
+	}, digests)
+
+	_, err = httpsignatures.ParseDigestHeader("SHA-256")
+	assert.EqualError(t, err, httpsignatures.ErrorMalformedDigestHeader)
+}
+
+func TestVerifyDigest(t *testing.T) {
+	body := `{"hello": "world"}`
+	for _, test := range []struct {
+		digest   string
+		expected string
+	}{
+		{"SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", ""},
+		{"sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", ""},
+		{"Sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=, MD5=unsupported", ""},
+		{"sha-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", httpsignatures.ErrorDigestMismatch},
+		{"md5=Sd/dVLAcvNLSq16eXua5uQ==", httpsignatures.ErrorUnsupportedDigestAlgorithm},
+		{"", httpsignatures.ErrorMissingRequiredHeader + " 'digest'"},
+	} {
+		r, err := http.NewRequest(http.MethodPost, "http://example.com/foo", strings.NewReader(body))
+		assert.Nil(t, err)
+		if test.digest != "" {
+			r.Header.Set("Digest", test.digest)
+		}
+
+		err = httpsignatures.VerifyDigest(r)
+		if test.expected == "" {
+			assert.Nil(t, err, test.digest)
+		} else {
+			assert.EqualError(t, err, test.expected, test.digest)
+		}
+
+		// the body can still be read
+		if test.digest != "" {
+			read, err := io.ReadAll(r.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, body, string(read))
+		}
+	}
+}
+
+func TestVerifyDigestMaxBodySize(t *testing.T) {
+	body := `{"hello": "world"}`
+	newRequest := func() *http.Request {
+		r, err := http.NewRequest(http.MethodPost, "http://example.com/foo", chunkedReader{strings.NewReader(body)})
+		assert.Nil(t, err)
+		r.Header.Set("Digest", "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=")
+		return r
+	}
+
+	err := httpsignatures.VerifyDigest(newRequest(), httpsignatures.WithMaxDigestBodySize(int64(len(body))))
+	assert.Nil(t, err)
+
+	err = httpsignatures.VerifyDigest(newRequest(), httpsignatures.WithMaxDigestBodySize(int64(len(body)-1)))
+	assert.EqualError(t, err, httpsignatures.ErrorBodyTooLargeForDigest)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr)
+}
+
+// unreadableBody fails the test when it is read
+type unreadableBody struct {
+	t *testing.T
+}
+
+func (b unreadableBody) Read(p []byte) (int, error) {
+	b.t.Error("the body is read")
+	return 0, io.EOF
+}
+
+func TestVerifyDigestExpected(t *testing.T) {
+	sum := sha256.Sum256([]byte(`{"hello": "world"}`))
+	r, err := http.NewRequest(http.MethodPost, "http://example.com/foo", unreadableBody{t})
+	assert.Nil(t, err)
+	r.Header.Set("Digest", "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=")
+
+	err = httpsignatures.VerifyDigest(r, httpsignatures.WithExpectedDigest("sha-256", sum[:]))
+	assert.Nil(t, err)
+
+	other := sha256.Sum256([]byte("other"))
+	err = httpsignatures.VerifyDigest(r, httpsignatures.WithExpectedDigest("SHA-256", other[:]))
+	assert.EqualError(t, err, httpsignatures.ErrorDigestMismatch)
+	err = httpsignatures.VerifyDigest(r, httpsignatures.WithExpectedDigest("SHA-512", sum[:]))
+	assert.EqualError(t, err, httpsignatures.ErrorDigestMismatch)
+}
+
+func TestVerifyDigestSkipOnReEncoding(t *testing.T) {
+	// the client digests the plain body, a proxy gzip encodes it afterwards
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	_, err := zw.Write([]byte(`{"hello": "world"}`))
+	assert.Nil(t, err)
+	assert.Nil(t, zw.Close())
+	newRequest := func(encoding string) *http.Request {
+		r, err := http.NewRequest(http.MethodPost, "http://example.com/foo", bytes.NewReader(gzipped.Bytes()))
+		assert.Nil(t, err)
+		r.Header.Set("Digest", "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=")
+		if encoding != "" {
+			r.Header.Set("Content-Encoding", encoding)
+		}
+		return r
+	}
+
+	err = httpsignatures.VerifyDigest(newRequest("gzip"))
+	assert.EqualError(t, err, httpsignatures.ErrorDigestMismatch)
+	err = httpsignatures.VerifyDigest(newRequest("gzip"), httpsignatures.WithSkipDigestOnReEncoding())
+	assert.Nil(t, err)
+
+	// without a content encoding the digest is still compared
+	err = httpsignatures.VerifyDigest(newRequest(""), httpsignatures.WithSkipDigestOnReEncoding())
+	assert.EqualError(t, err, httpsignatures.ErrorDigestMismatch)
+	err = httpsignatures.VerifyDigest(newRequest("identity"), httpsignatures.WithSkipDigestOnReEncoding())
+	assert.EqualError(t, err, httpsignatures.ErrorDigestMismatch)
+}
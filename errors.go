@@ -22,43 +22,112 @@ var (
 	ErrorDateHeaderIsMissingForClockSkewComparison = "Date header is missing for clockSkew comparison"
 	ErrorNoHeadersConfigLoaded                     = "No headers config loaded"
 	ErrorAlgorithmNotAllowed                       = "The used encryption algorithm is not allowed"
+	ErrorSensitiveHeaderNotSigned                  = "Sensitive header present in request but not signed"
+	ErrorUnknownKeyID                              = "Unknown keyId"
+	ErrorInvalidRSAKey                             = "Invalid RSA key"
+	ErrorUnknownKeyType                            = "Unable to derive the algorithm from the key"
+	ErrorSignatureTooWeak                          = "The signature strength is below the required minimum"
+	ErrorMalformedSignatureHeader                  = "Malformed signature header"
+	ErrorNoAllowedAlgorithmsConfigured             = "No allowed algorithms configured"
+	ErrorProfileMismatch                           = "The signature profile does not match the required profile"
+	ErrorInvalidKeyFile                            = "Invalid key file"
+	ErrorKeyAlgorithmMismatch                      = "The key does not match the algorithm"
+	ErrorDuplicateHeader                           = "Duplicate header in header list"
+	ErrorUnsupportedAlgorithmKind                  = "Custom algorithms of this kind are not supported"
+	ErrorBuiltinAlgorithm                          = "Built-in algorithms can not be replaced"
+	ErrorConflictingSignatureHeaders               = "The Signature and Authorization headers differ"
+	ErrorCreatedIsMissingForClockSkewComparison    = "Created parameter is missing for clockSkew comparison"
+	ErrorSignatureExpired                          = "The signature has expired"
+	ErrorUnknownSignatureParameter                 = "Unknown signature parameter"
+	ErrorKeyIDHeaderNotSigned                      = "The keyId header is not signed"
+	ErrorDateCreatedMismatch                       = "The date header and created parameter do not match"
+	ErrorAlgorithmNotAllowedForKey                 = "The algorithm is not allowed for the key"
+	ErrorPanicRecovered                            = "Recovered from a panic"
+	ErrorUnsupportedSignatureComponent             = "Unsupported signature component"
+	ErrorMalformedDigestHeader                     = "Malformed digest header"
+	ErrorDigestMismatch                            = "The digest does not match the body"
+	ErrorUnsupportedDigestAlgorithm                = "No supported digest algorithm in the digest header"
+	ErrorMissingCreatedParameter                   = "The (created) header is signed without created parameter"
+	ErrorBodyTooLargeForDigest                     = "The body is too large to verify the digest"
+	ErrorInvalidKeyEncoding                        = "The key is not valid base64"
+	ErrorForbiddenHeaderSigned                     = "Forbidden header is covered by the signature"
+	ErrorUnexpectedHeaderValue                     = "The signed header value differs from the expected value"
+	ErrorInvalidExpiry                             = "The signature expires before it was created"
+	ErrorAlgorithmNotSupportedByRFC9421            = "The algorithm is not supported for RFC 9421 signatures"
+	ErrorUnknownAlgorithm                          = "Unknown signature algorithm provided"
+	ErrorProfileNotSupportedByRFC9421              = "The profile parameter is not supported for RFC 9421 signatures"
+	ErrorHMACWithEd25519                           = "HMAC and ed25519 algorithms can not be combined"
+	ErrorSignatureLabelNotFound                    = "The signature label is not in the Signature-Input header"
 )
 
+// errorHTTPCodes maps the errors to their HTTP status code, an error string is
+// matched against the errors in this order
+var errorHTTPCodes = []struct {
+	err  string
+	code int
+}{
+	{ErrorNoAlgorithmConfigured, http.StatusInternalServerError},
+	{ErrorNoKeyIDConfigured, http.StatusInternalServerError},
+	{ErrorNoHeadersConfigLoaded, http.StatusInternalServerError},
+	{ErrorYouProbablyMisconfiguredAllowedClockSkew, http.StatusInternalServerError},
+	{ErrorNoAllowedAlgorithmsConfigured, http.StatusInternalServerError},
+	{ErrorInvalidKeyFile, http.StatusInternalServerError},
+	{ErrorKeyAlgorithmMismatch, http.StatusInternalServerError},
+	{ErrorDuplicateHeader, http.StatusInternalServerError},
+	{ErrorUnsupportedAlgorithmKind, http.StatusInternalServerError},
+	{ErrorBuiltinAlgorithm, http.StatusInternalServerError},
+	{ErrorPanicRecovered, http.StatusInternalServerError},
+	{ErrorAlgorithmNotSupportedByRFC9421, http.StatusInternalServerError},
+	{ErrorProfileNotSupportedByRFC9421, http.StatusInternalServerError},
+	{ErrorHMACWithEd25519, http.StatusInternalServerError},
+	{ErrorInvalidKeyEncoding, http.StatusInternalServerError},
+	{ErrorMissingRequiredHeader, http.StatusBadRequest},
+	{ErrorMissingSignatureParameterSignature, http.StatusBadRequest},
+	{ErrorMissingSignatureParameterAlgorithm, http.StatusBadRequest},
+	{ErrorMissingSignatureParameterKeyId, http.StatusBadRequest},
+	{ErrorNoSignatureHeaderFoundInRequest, http.StatusBadRequest},
+	{ErrorURLNotInRequest, http.StatusBadRequest},
+	{ErrorMethodNotInRequest, http.StatusBadRequest},
+	{ErrorSignaturesDoNotMatch, http.StatusBadRequest},
+	{ErrorAllowedClockskewExceeded, http.StatusBadRequest},
+	{ErrorRequiredHeaderNotInHeaderList, http.StatusBadRequest},
+	{ErrorDateHeaderIsMissingForClockSkewComparison, http.StatusBadRequest},
+	{ErrorAlgorithmNotAllowed, http.StatusBadRequest},
+	{ErrorSensitiveHeaderNotSigned, http.StatusBadRequest},
+	{ErrorUnknownKeyID, http.StatusBadRequest},
+	{ErrorSignatureTooWeak, http.StatusBadRequest},
+	{ErrorMalformedSignatureHeader, http.StatusBadRequest},
+	{ErrorProfileMismatch, http.StatusBadRequest},
+	{ErrorConflictingSignatureHeaders, http.StatusBadRequest},
+	{ErrorCreatedIsMissingForClockSkewComparison, http.StatusBadRequest},
+	{ErrorSignatureExpired, http.StatusBadRequest},
+	{ErrorUnknownSignatureParameter, http.StatusBadRequest},
+	{ErrorKeyIDHeaderNotSigned, http.StatusBadRequest},
+	{ErrorDateCreatedMismatch, http.StatusBadRequest},
+	{ErrorAlgorithmNotAllowedForKey, http.StatusBadRequest},
+	// clients can claim an algorithm which does not fit the key of the keyId
+	{ErrorInvalidRSAKey, http.StatusBadRequest},
+	{ErrorUnknownKeyType, http.StatusBadRequest},
+	{ErrorUnknownAlgorithm, http.StatusBadRequest},
+	{ErrorUnsupportedSignatureComponent, http.StatusBadRequest},
+	{ErrorSignatureLabelNotFound, http.StatusBadRequest},
+	{ErrorMalformedDigestHeader, http.StatusBadRequest},
+	{ErrorDigestMismatch, http.StatusBadRequest},
+	{ErrorUnsupportedDigestAlgorithm, http.StatusBadRequest},
+	{ErrorMissingCreatedParameter, http.StatusBadRequest},
+	{ErrorBodyTooLargeForDigest, http.StatusRequestEntityTooLarge},
+	{ErrorForbiddenHeaderSigned, http.StatusBadRequest},
+	{ErrorUnexpectedHeaderValue, http.StatusBadRequest},
+	{ErrorInvalidExpiry, http.StatusBadRequest},
+}
+
+// ErrorToHTTPCode returns the HTTP status code and message for the error
+// string, unknown errors are internal server errors
 func ErrorToHTTPCode(errString string) (int, string) {
-	switch {
-	case strings.HasPrefix(errString, ErrorNoAlgorithmConfigured):
-		return http.StatusInternalServerError, ErrorNoAlgorithmConfigured
-	case strings.HasPrefix(errString, ErrorNoKeyIDConfigured):
-		return http.StatusInternalServerError, ErrorNoKeyIDConfigured
-	case strings.HasPrefix(errString, ErrorNoHeadersConfigLoaded):
-		return http.StatusInternalServerError, ErrorNoHeadersConfigLoaded
-	case strings.HasPrefix(errString, ErrorYouProbablyMisconfiguredAllowedClockSkew):
-		return http.StatusInternalServerError, ErrorYouProbablyMisconfiguredAllowedClockSkew
-	case strings.HasPrefix(errString, ErrorMissingRequiredHeader):
-		return http.StatusBadRequest, ErrorMissingRequiredHeader
-	case strings.HasPrefix(errString, ErrorMissingSignatureParameterSignature):
-		return http.StatusBadRequest, ErrorMissingSignatureParameterSignature
-	case strings.HasPrefix(errString, ErrorMissingSignatureParameterAlgorithm):
-		return http.StatusBadRequest, ErrorMissingSignatureParameterAlgorithm
-	case strings.HasPrefix(errString, ErrorMissingSignatureParameterKeyId):
-		return http.StatusBadRequest, ErrorMissingSignatureParameterKeyId
-	case strings.HasPrefix(errString, ErrorNoSignatureHeaderFoundInRequest):
-		return http.StatusBadRequest, ErrorNoSignatureHeaderFoundInRequest
-	case strings.HasPrefix(errString, ErrorURLNotInRequest):
-		return http.StatusBadRequest, ErrorURLNotInRequest
-	case strings.HasPrefix(errString, ErrorMethodNotInRequest):
-		return http.StatusBadRequest, ErrorMethodNotInRequest
-	case strings.HasPrefix(errString, ErrorSignaturesDoNotMatch):
-		return http.StatusBadRequest, ErrorSignaturesDoNotMatch
-	case strings.HasPrefix(errString, ErrorAllowedClockskewExceeded):
-		return http.StatusBadRequest, ErrorAllowedClockskewExceeded
-	case strings.HasPrefix(errString, ErrorRequiredHeaderNotInHeaderList):
-		return http.StatusBadRequest, ErrorRequiredHeaderNotInHeaderList
-	case strings.HasPrefix(errString, ErrorDateHeaderIsMissingForClockSkewComparison):
-		return http.StatusBadRequest, ErrorDateHeaderIsMissingForClockSkewComparison
-	case strings.HasPrefix(errString, ErrorAlgorithmNotAllowed):
-		return http.StatusBadRequest, ErrorAlgorithmNotAllowed
-	default:
-		return http.StatusInternalServerError, errString
+	for _, e := range errorHTTPCodes {
+		if strings.HasPrefix(errString, e.err) {
+			return e.code, e.err
+		}
 	}
+	return http.StatusInternalServerError, errString
 }
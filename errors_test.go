@@ -0,0 +1,50 @@
+package httpsignatures
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestErrorToHTTPCodeIsExhaustive checks that every error declared in
+// errors.go has an entry in errorHTTPCodes
+func TestErrorToHTTPCodeIsExhaustive(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	assert.Nil(t, err)
+
+	mapped := map[string]bool{}
+	for _, e := range errorHTTPCodes {
+		mapped[e.err] = true
+	}
+
+	var count int
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "Error") {
+				continue
+			}
+			lit, ok := spec.Values[i].(*ast.BasicLit)
+			if !assert.True(t, ok, name.Name) {
+				continue
+			}
+			value, err := strconv.Unquote(lit.Value)
+			assert.Nil(t, err)
+			assert.True(t, mapped[value], "%s has no HTTP status code", name.Name)
+
+			code, msg := ErrorToHTTPCode(value + " 'detail'")
+			assert.Equal(t, value, msg, name.Name)
+			assert.NotZero(t, code)
+			count++
+		}
+		return false
+	})
+	assert.Equal(t, len(errorHTTPCodes), count)
+}
@@ -0,0 +1,64 @@
+package httpsignatures
+
+import (
+	"encoding/json"
+)
+
+type signatureParametersJSON struct {
+	KeyID           string       `json:"keyId"`
+	Algorithm       string       `json:"algorithm"`
+	Headers         HeaderValues `json:"headers"`
+	HeaderList      []string     `json:"headerList"`
+	Signature       string       `json:"signature"`
+	Profile         string       `json:"profile,omitempty"`
+	Created         int64        `json:"created,omitempty"`
+	Expires         int64        `json:"expires,omitempty"`
+	SignatureParams string       `json:"signatureParams,omitempty"`
+}
+
+// MarshalJSON encodes the signature parameters, the algorithm is encoded by its
+// name. RFC 9421 signatures keep their @signature-params, so they still
+// verify as RFC 9421 signatures after decoding.
+func (s SignatureParameters) MarshalJSON() ([]byte, error) {
+	v := signatureParametersJSON{
+		KeyID:           s.KeyID,
+		Headers:         s.Headers,
+		HeaderList:      s.HeaderList,
+		Signature:       s.Signature,
+		Profile:         s.Profile,
+		Created:         s.Created,
+		Expires:         s.Expires,
+		SignatureParams: s.signatureParams,
+	}
+	if s.Algorithm != nil {
+		v.Algorithm = s.Algorithm.Name
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes signature parameters encoded by MarshalJSON
+func (s *SignatureParameters) UnmarshalJSON(data []byte) error {
+	var v signatureParametersJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*s = SignatureParameters{
+		KeyID:           v.KeyID,
+		Headers:         v.Headers,
+		HeaderList:      v.HeaderList,
+		Signature:       v.Signature,
+		Profile:         v.Profile,
+		Created:         v.Created,
+		Expires:         v.Expires,
+		signatureParams: v.SignatureParams,
+	}
+	if v.Algorithm != "" {
+		alg, err := algorithmFromString(v.Algorithm)
+		if err != nil {
+			return err
+		}
+		s.Algorithm = alg
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package httpsignatures
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSignatureParametersJSONRoundTrip(t *testing.T) {
+	sigParam := SignatureParameters{KeyID: "Test", Algorithm: algorithmEd25519,
+		Headers:    HeaderValues{"(request-target)": "post /foo", "host": "example.com", "date": testDate},
+		HeaderList: []string{"(request-target)", "host", "date"}, Signature: "fffff"}
+
+	data, err := json.Marshal(sigParam)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"keyId":"Test","algorithm":"ed25519",
+		"headers":{"(request-target)":"post /foo","host":"example.com","date":"`+testDate+`"},
+		"headerList":["(request-target)","host","date"],"signature":"fffff"}`, string(data))
+
+	var s SignatureParameters
+	err = json.Unmarshal(data, &s)
+	assert.Nil(t, err)
+	assert.Equal(t, sigParam, s)
+}
+
+func TestSignatureParametersJSONRoundTripRFC9421(t *testing.T) {
+	// the HMAC example of RFC 9421
+	sigParam := SignatureParameters{KeyID: "test-shared-secret", Algorithm: algorithmHmacSha256,
+		Headers: HeaderValues{"date": "Tue, 20 Apr 2021 02:07:55 GMT", "@authority": "example.com",
+			"content-type": "application/json"},
+		HeaderList:      []string{"date", "@authority", "content-type"},
+		Signature:       "pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=",
+		Created:         1618884473,
+		signatureParams: `("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`}
+	key := "uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ=="
+
+	data, err := json.Marshal(sigParam)
+	assert.Nil(t, err)
+
+	var s SignatureParameters
+	err = json.Unmarshal(data, &s)
+	assert.Nil(t, err)
+	assert.Equal(t, sigParam, s)
+	res, err := s.Verify(key)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignatureParametersJSONUnknownAlgorithm(t *testing.T) {
+	var s SignatureParameters
+	err := json.Unmarshal([]byte(`{"keyId":"Test","algorithm":"rot13"}`), &s)
+	assert.Equal(t, errorUnknownAlgorithm, err)
+
+	err = json.Unmarshal([]byte(`{"keyId":"Test"}`), &s)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Algorithm)
+}
@@ -0,0 +1,214 @@
+package httpsignatures
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWKSResolver looks up keys in a JSON Web Key Set served at an URL
+type JWKSResolver struct {
+	url                string
+	cacheTTL           time.Duration
+	client             *http.Client
+	minRefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]string
+	fetched   time.Time
+	attempted time.Time
+	inflight  *jwksFetch
+}
+
+// jwksFetch is a fetch of the JWKS shared by the lookups waiting for it
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	X   string `json:"x"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+const (
+	// DefaultJWKSTimeout is the timeout of the default client fetching the JWKS
+	DefaultJWKSTimeout = 10 * time.Second
+	// DefaultJWKSMinRefreshInterval is the default minimum interval between
+	// fetches of the JWKS
+	DefaultJWKSMinRefreshInterval = 10 * time.Second
+)
+
+// JWKSOption configures a JWKSResolver
+type JWKSOption func(*JWKSResolver)
+
+// WithJWKSClient fetches the JWKS with client instead of a client with a
+// timeout of DefaultJWKSTimeout
+func WithJWKSClient(client *http.Client) JWKSOption {
+	return func(j *JWKSResolver) {
+		j.client = client
+	}
+}
+
+// WithJWKSMinRefreshInterval sets the minimum interval between fetches of the
+// JWKS, the default is DefaultJWKSMinRefreshInterval
+func WithJWKSMinRefreshInterval(d time.Duration) JWKSOption {
+	return func(j *JWKSResolver) {
+		j.minRefreshInterval = d
+	}
+}
+
+// NewJWKSResolver creates a resolver for the JWKS at url, which caches the
+// fetched keys for cacheTTL
+func NewJWKSResolver(url string, cacheTTL time.Duration, opts ...JWKSOption) *JWKSResolver {
+	j := &JWKSResolver{
+		url:                url,
+		cacheTTL:           cacheTTL,
+		client:             &http.Client{Timeout: DefaultJWKSTimeout},
+		minRefreshInterval: DefaultJWKSMinRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// KeyLookUp returns the base64 encoded key with `kid` keyID, it can be passed
+// as keyLookUp to VerifyRequest. See KeyLookUpContext.
+func (j *JWKSResolver) KeyLookUp(keyID string) (string, error) {
+	return j.KeyLookUpContext(context.Background(), keyID)
+}
+
+// KeyLookUpContext returns the base64 encoded key with `kid` keyID, it can be
+// passed as keyLookUp to VerifyRequestContext. The JWKS is fetched again when
+// the cache has expired or keyID is unknown, at most once per minimum refresh
+// interval, so clients sending unknown keyIds can not make the resolver fetch
+// the JWKS for every request. Concurrent lookups share a single fetch, ctx
+// only ends the wait for it. When the fetch fails, the expired key is returned
+// if it was cached, so an unavailable JWKS endpoint does not fail verification
+// of known keys.
+//
+// Symmetric keys ("oct") are returned as the raw key bytes, Ed25519 keys
+// ("OKP") as the raw public key and RSA keys as DER encoded PKIX public key.
+func (j *JWKSResolver) KeyLookUpContext(ctx context.Context, keyID string) (string, error) {
+	j.mu.Lock()
+	key, ok := j.keys[keyID]
+	stale := !ok || time.Since(j.fetched) >= j.cacheTTL
+	refresh := stale && (j.inflight != nil || time.Since(j.attempted) >= j.minRefreshInterval)
+	if !refresh {
+		j.mu.Unlock()
+		return jwksKey(keyID, key, ok)
+	}
+	f := j.inflight
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		j.inflight = f
+		j.attempted = time.Now()
+		go j.fetch(f)
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if f.err != nil {
+		if ok {
+			return key, nil
+		}
+		return "", f.err
+	}
+
+	j.mu.Lock()
+	key, ok = j.keys[keyID]
+	j.mu.Unlock()
+	return jwksKey(keyID, key, ok)
+}
+
+func jwksKey(keyID, key string, ok bool) (string, error) {
+	if !ok {
+		return "", fmt.Errorf("%s '%s'", ErrorUnknownKeyID, keyID)
+	}
+	return key, nil
+}
+
+// fetch fetches the JWKS without holding the lock and completes f
+func (j *JWKSResolver) fetch(f *jwksFetch) {
+	keys, err := j.fetchKeys()
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+		j.fetched = time.Now()
+	}
+	j.inflight = nil
+	f.err = err
+	j.mu.Unlock()
+	close(f.done)
+}
+
+func (j *JWKSResolver) fetchKeys() (map[string]string, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]string{}
+	for _, jwk := range set.Keys {
+		key, err := jwk.keyBytes()
+		if err != nil {
+			// skip keys of a type that can not be used for verification
+			continue
+		}
+		keys[jwk.Kid] = base64.StdEncoding.EncodeToString(key)
+	}
+	return keys, nil
+}
+
+func (jwk jsonWebKey) keyBytes() ([]byte, error) {
+	switch {
+	case jwk.Kty == "oct":
+		return base64.RawURLEncoding.DecodeString(jwk.K)
+	case jwk.Kty == "OKP" && jwk.Crv == "Ed25519":
+		return base64.RawURLEncoding.DecodeString(jwk.X)
+	case jwk.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKIXPublicKey(&rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		})
+	}
+	return nil, errors.New("unsupported JSON web key type")
+}
@@ -0,0 +1,231 @@
+package httpsignatures_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func jwksServer(keys *[]string, fetches *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*fetches++
+		fmt.Fprint(w, `{"keys":[`)
+		for i, key := range *keys {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprint(w, key)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+}
+
+func toBase64URL(t *testing.T, b64 string) string {
+	b, err := base64.StdEncoding.DecodeString(b64)
+	assert.Nil(t, err)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestJWKSResolverCachesKeys(t *testing.T) {
+	keys := []string{
+		fmt.Sprintf(`{"kty":"oct","kid":"hmac","k":"%s"}`, toBase64URL(t, testKey)),
+		fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","kid":"ed","x":"%s"}`, toBase64URL(t, ed25519TestPublicKey)),
+		`{"kty":"EC","crv":"P-256","kid":"ec","x":"AA","y":"AA"}`,
+	}
+	fetches := 0
+	server := jwksServer(&keys, &fetches)
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour, httpsignatures.WithJWKSMinRefreshInterval(0))
+
+	key, err := resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	assert.Equal(t, testKey, key)
+	key, err = resolver.KeyLookUp("ed")
+	assert.Nil(t, err)
+	assert.Equal(t, ed25519TestPublicKey, key)
+	assert.Equal(t, 1, fetches)
+
+	// unknown and unsupported keys refresh the cache
+	_, err = resolver.KeyLookUp("ec")
+	assert.EqualError(t, err, httpsignatures.ErrorUnknownKeyID+" 'ec'")
+	assert.Equal(t, 2, fetches)
+
+	keys = append(keys, fmt.Sprintf(`{"kty":"oct","kid":"new","k":"%s"}`, toBase64URL(t, testKey)))
+	key, err = resolver.KeyLookUp("new")
+	assert.Nil(t, err)
+	assert.Equal(t, testKey, key)
+	assert.Equal(t, 3, fetches)
+}
+
+func TestJWKSResolverRespectsTTL(t *testing.T) {
+	keys := []string{fmt.Sprintf(`{"kty":"oct","kid":"hmac","k":"%s"}`, toBase64URL(t, testKey))}
+	fetches := 0
+	server := jwksServer(&keys, &fetches)
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, 0, httpsignatures.WithJWKSMinRefreshInterval(0))
+	_, err := resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	_, err = resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, fetches)
+
+	// the expired cache is not fetched again within the minimum refresh interval
+	resolver = httpsignatures.NewJWKSResolver(server.URL, 0)
+	_, err = resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	_, err = resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, fetches)
+}
+
+func TestJWKSResolverServesStaleKeys(t *testing.T) {
+	failing := false
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":"hmac","k":"%s"}]}`, toBase64URL(t, testKey))
+	}))
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Millisecond, httpsignatures.WithJWKSMinRefreshInterval(0))
+	_, err := resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+
+	// the TTL expires, and the endpoint fails
+	failing = true
+	time.Sleep(2 * time.Millisecond)
+	key, err := resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+	assert.Equal(t, testKey, key)
+	assert.Equal(t, 2, fetches)
+
+	// unknown keys still report the failed fetch
+	_, err = resolver.KeyLookUp("other")
+	assert.EqualError(t, err, "fetching JWKS: 503 Service Unavailable")
+	assert.Equal(t, 3, fetches)
+}
+
+func TestJWKSResolverRSAKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	e := base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1})
+	n := base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes())
+	keys := []string{fmt.Sprintf(`{"kty":"RSA","kid":"rsa","n":"%s","e":"%s"}`, n, e)}
+	fetches := 0
+	server := jwksServer(&keys, &fetches)
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour)
+	key, err := resolver.KeyLookUp("rsa")
+	assert.Nil(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(der), key)
+}
+
+func TestJWKSResolverVerifiesRequest(t *testing.T) {
+	keys := []string{fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","kid":"ed","x":"%s"}`, toBase64URL(t, ed25519TestPublicKey))}
+	fetches := 0
+	server := jwksServer(&keys, &fetches)
+	defer server.Close()
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner("ed25519")
+	err := signer.SignRequest(r, "ed", ed25519TestPrivateKey)
+	assert.Nil(t, err)
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour)
+	res, err := httpsignatures.VerifyRequest(r, resolver.KeyLookUp, -1, []string{httpsignatures.AlgorithmEd25519})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestJWKSResolverLimitsRefreshForUnknownKeys(t *testing.T) {
+	keys := []string{fmt.Sprintf(`{"kty":"oct","kid":"hmac","k":"%s"}`, toBase64URL(t, testKey))}
+	fetches := 0
+	server := jwksServer(&keys, &fetches)
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour)
+	_, err := resolver.KeyLookUp("hmac")
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = resolver.KeyLookUp(fmt.Sprintf("random-%d", i))
+		assert.EqualError(t, err, fmt.Sprintf("%s 'random-%d'", httpsignatures.ErrorUnknownKeyID, i))
+	}
+	assert.Equal(t, 1, fetches)
+}
+
+func TestJWKSResolverSharesFetch(t *testing.T) {
+	var mu sync.Mutex
+	fetches := 0
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		<-release
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":"hmac","k":"%s"}]}`, toBase64URL(t, testKey))
+	}))
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour)
+
+	// a lookup gives up waiting when its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := resolver.KeyLookUpContext(ctx, "hmac")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := resolver.KeyLookUpContext(context.Background(), "hmac")
+			assert.Nil(t, err)
+			assert.Equal(t, testKey, key)
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, fetches)
+}
+
+func TestJWKSResolverClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	resolver := httpsignatures.NewJWKSResolver(server.URL, time.Hour,
+		httpsignatures.WithJWKSClient(&http.Client{Timeout: 10 * time.Millisecond}))
+	_, err := resolver.KeyLookUp("hmac")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,66 @@
+package httpsignatures
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NewSignerFromFile creates a signer for algorithm with the PEM encoded
+// private key read from keyPath. The key is read and parsed once, and must be
+// an RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8) key matching the algorithm.
+// SignRequest and AuthRequest use this key when they are called with an empty
+// keyB64.
+func NewSignerFromFile(algorithm, keyPath string, headers ...string) (*signer, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	keyB64, err := privateKeyFromPEM(algorithm, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s '%s': %v", ErrorInvalidKeyFile, keyPath, err)
+	}
+
+	s := NewSigner(algorithm, headers...)
+	s.keyB64 = keyB64
+	return s, nil
+}
+
+// privateKeyFromPEM returns the base64 encoded key in the format the sign
+// function of algorithm expects
+func privateKeyFromPEM(algorithm string, data []byte) (string, error) {
+	alg, err := algorithmFromString(algorithm)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", errors.New("no PEM data found")
+	}
+
+	var key interface{}
+	if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		return "", err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if alg.Kind == AlgorithmKindRSAPKCS1v15 || alg.Kind == AlgorithmKindRSAPSS || alg.Kind == AlgorithmKindHs2019 {
+			return base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key)), nil
+		}
+	case ed25519.PrivateKey:
+		if alg.Kind == AlgorithmKindEd25519 || alg.Kind == AlgorithmKindHs2019 {
+			return base64.StdEncoding.EncodeToString(key), nil
+		}
+	default:
+		return "", errors.New(ErrorUnknownKeyType)
+	}
+	return "", errors.New(ErrorKeyAlgorithmMismatch)
+}
@@ -0,0 +1,108 @@
+package httpsignatures_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.Nil(t, err)
+	return path
+}
+
+func TestNewSignerFromFileRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	pubKey := base64.StdEncoding.EncodeToString(der)
+
+	for _, path := range []string{
+		writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		writePEM(t, "PRIVATE KEY", mustMarshalPKCS8(t, key)),
+	} {
+		signer, err := httpsignatures.NewSignerFromFile(httpsignatures.AlgorithmRsaSha256, path)
+		assert.Nil(t, err)
+
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{testDate},
+			},
+		}
+		err = signer.SignRequest(r, testKeyID, "")
+		assert.Nil(t, err)
+
+		rsaKeyLookUp := func(keyID string) (string, error) {
+			return pubKey, nil
+		}
+		res, err := httpsignatures.VerifyRequest(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmRsaSha256})
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}
+}
+
+func TestNewSignerFromFileEd25519(t *testing.T) {
+	privKey, err := base64.StdEncoding.DecodeString(ed25519TestPrivateKey)
+	assert.Nil(t, err)
+	path := writePEM(t, "PRIVATE KEY", mustMarshalPKCS8(t, ed25519.PrivateKey(privKey)))
+
+	signer, err := httpsignatures.NewSignerFromFile(httpsignatures.AlgorithmEd25519, path)
+	assert.Nil(t, err)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = signer.SignRequest(r, testKeyID, "")
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, ed25519TestSignature, s.Signature)
+}
+
+func TestNewSignerFromFileErrors(t *testing.T) {
+	_, err := httpsignatures.NewSignerFromFile(httpsignatures.AlgorithmRsaSha256, filepath.Join(t.TempDir(), "missing.pem"))
+	assert.True(t, os.IsNotExist(err))
+
+	path := filepath.Join(t.TempDir(), "garbage.pem")
+	err = os.WriteFile(path, []byte("not a key"), 0600)
+	assert.Nil(t, err)
+	_, err = httpsignatures.NewSignerFromFile(httpsignatures.AlgorithmRsaSha256, path)
+	assert.Contains(t, err.Error(), httpsignatures.ErrorInvalidKeyFile)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+
+	path = writePEM(t, "PRIVATE KEY", []byte("not a key"))
+	_, err = httpsignatures.NewSignerFromFile(httpsignatures.AlgorithmRsaSha256, path)
+	assert.Contains(t, err.Error(), httpsignatures.ErrorInvalidKeyFile)
+
+	privKey, err := base64.StdEncoding.DecodeString(ed25519TestPrivateKey)
+	assert.Nil(t, err)
+	path = writePEM(t, "PRIVATE KEY", mustMarshalPKCS8(t, ed25519.PrivateKey(privKey)))
+	for _, algorithm := range []string{httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHmacSha256} {
+		_, err = httpsignatures.NewSignerFromFile(algorithm, path)
+		assert.Contains(t, err.Error(), httpsignatures.ErrorKeyAlgorithmMismatch)
+	}
+}
+
+func mustMarshalPKCS8(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	return der
+}
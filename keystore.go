@@ -0,0 +1,103 @@
+package httpsignatures
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DirKeyStore looks up public keys stored as `<keyId>.pem` files in a directory
+type DirKeyStore struct {
+	dir string
+
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+// NewDirKeyStore loads the public keys from the `.pem` files in dir, the file
+// name without extension is the keyId. It fails on files which do not hold a
+// PEM encoded PKIX or PKCS#1 public key.
+func NewDirKeyStore(dir string) (*DirKeyStore, error) {
+	d := &DirKeyStore{dir: dir}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload loads the keys from the directory again, replacing the keys in the
+// store only when all files can be loaded
+func (d *DirKeyStore) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(d.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]string{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := publicKeyFromPEM(data)
+		if err != nil {
+			return fmt.Errorf("%s '%s': %v", ErrorInvalidKeyFile, path, err)
+		}
+		keys[strings.TrimSuffix(filepath.Base(path), ".pem")] = key
+	}
+
+	d.mu.Lock()
+	d.keys = keys
+	d.mu.Unlock()
+	return nil
+}
+
+// KeyLookUp returns the base64 encoded key with keyID, it can be passed as
+// keyLookUp to VerifyRequest. Ed25519 keys are returned as the raw public key
+// and RSA keys as DER encoded PKIX public key.
+func (d *DirKeyStore) KeyLookUp(keyID string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	key, ok := d.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%s '%s'", ErrorUnknownKeyID, keyID)
+	}
+	return key, nil
+}
+
+// publicKeyFromPEM returns the base64 encoded key in the format the verify
+// functions expect
+func publicKeyFromPEM(data []byte) (string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", errors.New("no PEM data found")
+	}
+
+	if rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		der, err := x509.MarshalPKIXPublicKey(rsaKey)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(der), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return base64.StdEncoding.EncodeToString(key), nil
+	case *rsa.PublicKey:
+		return base64.StdEncoding.EncodeToString(block.Bytes), nil
+	}
+	return "", errors.New(ErrorUnknownKeyType)
+}
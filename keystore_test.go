@@ -0,0 +1,93 @@
+package httpsignatures_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func writePublicKey(t *testing.T, dir, keyID, blockType string, der []byte) {
+	err := os.WriteFile(filepath.Join(dir, keyID+".pem"),
+		pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.Nil(t, err)
+}
+
+func TestDirKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	pubKey, err := base64.StdEncoding.DecodeString(ed25519TestPublicKey)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(ed25519.PublicKey(pubKey))
+	assert.Nil(t, err)
+	writePublicKey(t, dir, "ed25519-key", "PUBLIC KEY", der)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	writePublicKey(t, dir, "rsa-key", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+
+	store, err := httpsignatures.NewDirKeyStore(dir)
+	assert.Nil(t, err)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = httpsignatures.NewSigner(httpsignatures.AlgorithmEd25519).SignRequest(r, "ed25519-key", ed25519TestPrivateKey)
+	assert.Nil(t, err)
+	res, err := httpsignatures.VerifyRequest(r, store.KeyLookUp, -1, []string{httpsignatures.AlgorithmEd25519})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Del("Signature")
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(rsaKey))
+	err = httpsignatures.NewSigner(httpsignatures.AlgorithmRsaSha256).SignRequest(r, "rsa-key", privKey)
+	assert.Nil(t, err)
+	res, err = httpsignatures.VerifyRequest(r, store.KeyLookUp, -1, []string{httpsignatures.AlgorithmRsaSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	_, err = store.KeyLookUp("unknown")
+	assert.EqualError(t, err, httpsignatures.ErrorUnknownKeyID+" 'unknown'")
+}
+
+func TestDirKeyStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := httpsignatures.NewDirKeyStore(dir)
+	assert.Nil(t, err)
+	_, err = store.KeyLookUp("ed25519-key")
+	assert.EqualError(t, err, httpsignatures.ErrorUnknownKeyID+" 'ed25519-key'")
+
+	pubKey, err := base64.StdEncoding.DecodeString(ed25519TestPublicKey)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(ed25519.PublicKey(pubKey))
+	assert.Nil(t, err)
+	writePublicKey(t, dir, "ed25519-key", "PUBLIC KEY", der)
+
+	err = store.Reload()
+	assert.Nil(t, err)
+	key, err := store.KeyLookUp("ed25519-key")
+	assert.Nil(t, err)
+	assert.Equal(t, ed25519TestPublicKey, key)
+
+	// a malformed file fails the reload and keeps the loaded keys
+	err = os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600)
+	assert.Nil(t, err)
+	err = store.Reload()
+	assert.Contains(t, err.Error(), httpsignatures.ErrorInvalidKeyFile)
+	key, err = store.KeyLookUp("ed25519-key")
+	assert.Nil(t, err)
+	assert.Equal(t, ed25519TestPublicKey, key)
+
+	_, err = httpsignatures.NewDirKeyStore(dir)
+	assert.Contains(t, err.Error(), httpsignatures.ErrorInvalidKeyFile)
+}
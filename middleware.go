@@ -0,0 +1,104 @@
+package httpsignatures
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+)
+
+// VerifyHandler returns a handler which verifies the signature of every
+// request like VerifyRequestContext and passes the verified requests to next.
+// Requests which fail the verification are answered with the status code of
+// ErrorToHTTPCode. Panics in the key lookup and in next are recovered and
+// answered with 500 Internal Server Error, without details of the panic. If
+// next already started the response, the panic is replaced by
+// http.ErrAbortHandler, so net/http aborts the partial response. The
+// http.ErrAbortHandler panic is not recovered either.
+// WithKeyLookupErrorStatus sets the status code for failed key lookups.
+func VerifyHandler(next http.Handler, keyLookUp func(ctx context.Context, keyID string) (string, error),
+	allowedClockSkew int, allowedAlgorithms []string, requiredHeaders []string, opts ...Option) http.Handler {
+	keyLookupErrorStatus := newOptions(opts).keyLookupErrorStatus
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := &trackingWriter{ResponseWriter: rw}
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler || w.written {
+					panic(http.ErrAbortHandler)
+				}
+				writeError(w, ErrorPanicRecovered)
+			}
+		}()
+
+		var lookupErr error
+		lookUp := func(ctx context.Context, keyID string) (string, error) {
+			key, err := keyLookUp(ctx, keyID)
+			lookupErr = err
+			return key, err
+		}
+		valid, err := VerifyRequestContext(r.Context(), r, lookUp, allowedClockSkew, allowedAlgorithms,
+			requiredHeaders, opts...)
+		if err != nil && lookupErr != nil && keyLookupErrorStatus != 0 {
+			http.Error(w, http.StatusText(keyLookupErrorStatus), keyLookupErrorStatus)
+			return
+		}
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		if !valid {
+			writeError(w, ErrorSignaturesDoNotMatch)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trackingWriter records whether the response was started
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response if the underlying writer supports it
+func (w *trackingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection if the underlying writer supports it
+func (w *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.written = true
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeError answers with the status code of the error, the message of
+// internal errors is not sent to the client
+func writeError(w http.ResponseWriter, errString string) {
+	httpErr, msg := ErrorToHTTPCode(errString)
+	if httpErr == http.StatusInternalServerError {
+		msg = http.StatusText(httpErr)
+	}
+	http.Error(w, msg, httpErr)
+}
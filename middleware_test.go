@@ -0,0 +1,170 @@
+package httpsignatures_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func TestVerifyHandler(t *testing.T) {
+	keyLookUpContext := func(ctx context.Context, keyID string) (string, error) {
+		switch keyID {
+		case "panic":
+			panic("key store failure: secret details")
+		case "unknown":
+			return "", context.DeadlineExceeded
+		}
+		return testKey, nil
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("handler failure: secret details")
+		}
+		w.Write([]byte("verified"))
+	})
+	handler := httpsignatures.VerifyHandler(next, keyLookUpContext, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil)
+
+	for _, test := range []struct {
+		keyID  string
+		path   string
+		sign   bool
+		status int
+		body   string
+	}{
+		{testKeyID, "/foo", true, http.StatusOK, "verified"},
+		{testKeyID, "/foo", false, http.StatusBadRequest, httpsignatures.ErrorNoSignatureHeaderFoundInRequest},
+		{"unknown", "/foo", true, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)},
+		{"panic", "/foo", true, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)},
+		{testKeyID, "/panic", true, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)},
+	} {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.Header.Set("Date", testDate)
+		if test.sign {
+			err := DefaultSha256Signer.SignRequest(r, test.keyID, testKey)
+			assert.Nil(t, err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		body, _ := io.ReadAll(w.Body)
+		assert.Equal(t, test.status, w.Code, test.keyID+test.path)
+		assert.Equal(t, test.body, strings.TrimSpace(string(body)), test.keyID+test.path)
+	}
+}
+
+func TestVerifyHandlerKeyLookupErrorStatus(t *testing.T) {
+	keyLookUpContext := func(ctx context.Context, keyID string) (string, error) {
+		if keyID != testKeyID {
+			return "", errors.New(httpsignatures.ErrorUnknownKeyID)
+		}
+		return testKey, nil
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("verified"))
+	})
+	handler := httpsignatures.VerifyHandler(next, keyLookUpContext, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithKeyLookupErrorStatus(http.StatusUnauthorized))
+
+	for _, test := range []struct {
+		keyID  string
+		status int
+	}{
+		{testKeyID, http.StatusOK},
+		{"unknown", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+		r.Header.Set("Date", testDate)
+		err := DefaultSha256Signer.SignRequest(r, test.keyID, testKey)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, test.status, w.Code, test.keyID)
+	}
+
+	// errors other than of the key lookup keep their status code
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestVerifyHandlerPanicAfterWrite(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/abort":
+			panic(http.ErrAbortHandler)
+		case "/written":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("partial"))
+			panic("handler failure after writing")
+		}
+	})
+	handler := httpsignatures.VerifyHandler(next, func(ctx context.Context, keyID string) (string, error) {
+		return testKey, nil
+	}, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil)
+
+	newRequest := func(path string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Date", testDate)
+		err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+		return r
+	}
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("/abort"))
+	})
+
+	// the started response is aborted instead of completed
+	w := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(w, newRequest("/written"))
+	})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+}
+
+func TestVerifyHandlerHijack(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})
+	server := httptest.NewServer(httpsignatures.VerifyHandler(next, func(ctx context.Context, keyID string) (string, error) {
+		return testKey, nil
+	}, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodGet, server.URL+"/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err := http.DefaultClient.Do(r)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "hijacked", string(body))
+
+	// a writer without hijacking support reports it
+	w := httptest.NewRecorder()
+	httpsignatures.VerifyHandler(next, func(ctx context.Context, keyID string) (string, error) {
+		return testKey, nil
+	}, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,572 @@
+package httpsignatures
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures optional behaviour of the signer or the verification
+type Option func(*options)
+
+type options struct {
+	verifyHook                  VerifyHook
+	encoding                    SignatureEncoding
+	keyAlgorithms               func(keyID string) ([]string, error)
+	keyAlgorithm                func(keyID string) (string, error)
+	signedIfPresent             []string
+	forbiddenHeaders            []string
+	lineEnding                  string
+	expectedHeaders             map[string]string
+	keyLookupErrorStatus        int
+	created                     time.Time
+	normalizeUnicode            bool
+	minStrength                 int
+	profile                     string
+	requiredProfile             string
+	trustedHostHeader           string
+	authorizationPrecedence     bool
+	rejectConflictingSignatures bool
+	freshnessSource             FreshnessSource
+	expiry                      time.Duration
+	strictParameters            bool
+	legacyFragmentInTarget      bool
+	optionalHeaders             []string
+	keyIDHeader                 string
+	dateCreatedSkew             time.Duration
+	preserveWhitespace          bool
+	signingStringInError        bool
+	clockSkewSet                bool
+	clockSkew                   time.Duration
+	defaultClockSkew            bool
+	signatureHeader             string
+	clock                       func() time.Time
+	rfc9421                     bool
+	signatureLabel              string
+	decodedPath                 bool
+	keySource                   KeySource
+	maxDigestBodySize           int64
+	expectedDigestAlgorithm     string
+	expectedDigest              []byte
+	skipDigestOnReEncoding      bool
+	rawHMACKey                  bool
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// VerifyEvent identifies a decision point of the verification
+type VerifyEvent int
+
+const (
+	// VerifyEventAlgorithmChecked is reported after the algorithm is checked
+	// against the allowed algorithms
+	VerifyEventAlgorithmChecked VerifyEvent = iota
+	// VerifyEventClockSkewChecked is reported after the clock skew is checked
+	VerifyEventClockSkewChecked
+	// VerifyEventKeyLookedUp is reported after the key is looked up
+	VerifyEventKeyLookedUp
+	// VerifyEventVerified is reported after the signature is verified
+	VerifyEventVerified
+)
+
+// VerifyHook is called at each decision point of the verification with the
+// keyID and algorithm of the signature, err is nil if the check passed
+type VerifyHook func(event VerifyEvent, keyID string, algorithm string, err error)
+
+// WithVerifyHook calls hook at each decision point of the verification,
+// e.g. for logging or tracing
+func WithVerifyHook(hook VerifyHook) Option {
+	return func(o *options) {
+		o.verifyHook = hook
+	}
+}
+
+// WithKeyAlgorithms derives the allowed algorithms from the metadata of the key
+// when the verification is called with an empty allowedAlgorithms list, so the
+// algorithm policy can be kept with the keys
+func WithKeyAlgorithms(keyAlgorithms func(keyID string) ([]string, error)) Option {
+	return func(o *options) {
+		o.keyAlgorithms = keyAlgorithms
+	}
+}
+
+// WithKeyAlgorithm binds each key to a single algorithm: signatures claiming
+// another algorithm for the keyId are rejected, even when that algorithm is
+// allowed. This prevents algorithm confusion, such as verifying an hmac
+// signature with the public key of a RSA key pair as the secret.
+func WithKeyAlgorithm(keyAlgorithm func(keyID string) (string, error)) Option {
+	return func(o *options) {
+		o.keyAlgorithm = keyAlgorithm
+	}
+}
+
+// WithKeyAlgorithmMap binds each keyId to the algorithm it maps to, like
+// WithKeyAlgorithm. Signatures by keys which are not in the map are rejected.
+func WithKeyAlgorithmMap(algorithms map[string]string) Option {
+	pinned := make(map[string]string, len(algorithms))
+	for keyID, algorithm := range algorithms {
+		pinned[keyID] = algorithm
+	}
+	return WithKeyAlgorithm(func(keyID string) (string, error) {
+		if algorithm, ok := pinned[keyID]; ok {
+			return algorithm, nil
+		}
+		return "", fmt.Errorf("%s: '%s'", ErrorAlgorithmNotAllowedForKey, keyID)
+	})
+}
+
+// WithSignedIfPresent requires each of the headers that is present in the request
+// to be covered by the signature, see DefaultSensitiveHeaders
+func WithSignedIfPresent(headers ...string) Option {
+	return func(o *options) {
+		o.signedIfPresent = headers
+	}
+}
+
+// WithForbiddenHeaders rejects signatures covering any of the headers, eg
+// headers which a proxy in front of the server rewrites
+func WithForbiddenHeaders(headers ...string) Option {
+	return func(o *options) {
+		o.forbiddenHeaders = headers
+	}
+}
+
+// WithExpectedHeaderValue requires the header to be signed with value, which
+// the server knows independently of the request, eg its own public host when
+// the host is taken from WithTrustedHostHeader. The host is compared case
+// insensitively. It can be passed more than once for different headers.
+func WithExpectedHeaderValue(header, value string) Option {
+	return func(o *options) {
+		expected := make(map[string]string, len(o.expectedHeaders)+1)
+		for h, v := range o.expectedHeaders {
+			expected[h] = v
+		}
+		expected[headerName(header)] = value
+		o.expectedHeaders = expected
+	}
+}
+
+// WithKeyLookupErrorStatus makes VerifyHandler answer requests for which the
+// key lookup fails with the status code, whatever the error of the lookup is
+func WithKeyLookupErrorStatus(code int) Option {
+	return func(o *options) {
+		o.keyLookupErrorStatus = code
+	}
+}
+
+// WithUnicodeNormalization applies Unicode NFC normalization to the signing
+// string, so composed and decomposed characters in header values match.
+// Both the signer and the verification must use this option.
+func WithUnicodeNormalization() Option {
+	return func(o *options) {
+		o.normalizeUnicode = true
+	}
+}
+
+// WithMinStrength rejects signatures with a SignatureStrength below score
+func WithMinStrength(score int) Option {
+	return func(o *options) {
+		o.minStrength = score
+	}
+}
+
+// WithProfile makes the signer emit the profile parameter with name, which is
+// signed by adding the (profile) pseudo header to the headers. Signers with
+// WithRFC9421 fail to sign, as RFC 9421 has no profile parameter.
+func WithProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// WithRequiredProfile rejects signatures without a profile parameter equal to
+// name, or which do not sign it with the (profile) pseudo header
+func WithRequiredProfile(name string) Option {
+	return func(o *options) {
+		o.requiredProfile = name
+	}
+}
+
+// WithTrustedHostHeader makes the verification use the value of header, eg
+// X-Forwarded-Host, as the signed host when the request carries it, so
+// signatures created against the original host verify behind a reverse proxy
+// which rewrites the Host header. Only use this when the header is set by a
+// proxy you trust, as it lets the client choose the host that is verified.
+func WithTrustedHostHeader(header string) Option {
+	return func(o *options) {
+		o.trustedHostHeader = header
+	}
+}
+
+// trustedHost returns the original host from the trusted host header, which
+// is the first entry when proxies appended to it
+func (o options) trustedHost(r *http.Request) string {
+	if o.trustedHostHeader == "" {
+		return ""
+	}
+	host := strings.Split(r.Header.Get(o.trustedHostHeader), ",")[0]
+	return strings.TrimSpace(host)
+}
+
+// WithAuthorizationPrecedence verifies the signature in the Authorization
+// header when a request carries both a Signature and an Authorization header
+// with the Signature scheme. By default the Signature header is verified.
+func WithAuthorizationPrecedence() Option {
+	return func(o *options) {
+		o.authorizationPrecedence = true
+	}
+}
+
+// WithRejectConflictingSignatures rejects requests carrying both a Signature
+// and an Authorization header with the Signature scheme when they differ
+func WithRejectConflictingSignatures() Option {
+	return func(o *options) {
+		o.rejectConflictingSignatures = true
+	}
+}
+
+// FreshnessSource selects the signing time used for the clock skew check
+type FreshnessSource int
+
+const (
+	// FreshnessSourceDate uses the X-Date or Date header
+	FreshnessSourceDate FreshnessSource = iota
+	// FreshnessSourceCreated uses the signed created parameter, ignoring the
+	// Date header even if present
+	FreshnessSourceCreated
+	// FreshnessSourceAuto uses the signed created parameter if present, and
+	// the X-Date or Date header otherwise
+	FreshnessSourceAuto
+)
+
+// WithFreshnessSource sets the signing time used for the clock skew check,
+// the default is FreshnessSourceDate
+func WithFreshnessSource(source FreshnessSource) Option {
+	return func(o *options) {
+		o.freshnessSource = source
+	}
+}
+
+// WithClockSkew sets the allowed difference between the signing time and now,
+// in either direction, overriding the allowedClockSkew argument of the
+// verification. Signing times have a precision of one second, a d of 0 allows
+// no skew at that precision. A negative d is treated as 0, use
+// WithoutClockSkewCheck to disable the check.
+func WithClockSkew(d time.Duration) Option {
+	return func(o *options) {
+		if d < 0 {
+			d = 0
+		}
+		o.clockSkewSet = true
+		o.clockSkew = d
+	}
+}
+
+// WithClock sets the clock used for the created and expires parameters of the
+// signer and the clock skew and expiry checks of the verification, so they
+// can be tested deterministically. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.clock = now
+	}
+}
+
+// now returns the current time of the clock
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock()
+}
+
+// WithoutClockSkewCheck disables the clock skew check, overriding the
+// allowedClockSkew argument of the verification
+func WithoutClockSkewCheck() Option {
+	return func(o *options) {
+		o.clockSkewSet = true
+		o.clockSkew = -1
+	}
+}
+
+// WithDefaultClockSkew sets the allowed clock skew to the one set with
+// SetDefaultClockSkew at the time of the verification, overriding the
+// allowedClockSkew argument of the verification
+func WithDefaultClockSkew() Option {
+	return func(o *options) {
+		o.defaultClockSkew = true
+	}
+}
+
+// WithCreated makes the signer sign at t instead of the current time, for the
+// created and expires parameters. When the date header is signed, SignRequest,
+// AuthRequest and SignedClone set the Date header of the request to t as well,
+// so both carry the same time.
+func WithCreated(t time.Time) Option {
+	return func(o *options) {
+		o.created = t
+	}
+}
+
+// WithExpiry makes the signer emit an expires parameter d after signing,
+// which can be signed by adding (expires) to the headers
+func WithExpiry(d time.Duration) Option {
+	return func(o *options) {
+		o.expiry = d
+	}
+}
+
+// WithStrictParameters rejects signatures with parameters other than keyId,
+// algorithm, headers, signature, profile, created and expires. By default
+// unknown parameters are ignored.
+func WithStrictParameters() Option {
+	return func(o *options) {
+		o.strictParameters = true
+	}
+}
+
+// WithLegacyFragmentInTarget includes the URL fragment in (request-target),
+// for compatibility with clients which signed it. The fragment is never sent
+// to the server, so it is omitted by default.
+func WithLegacyFragmentInTarget() Option {
+	return func(o *options) {
+		o.legacyFragmentInTarget = true
+	}
+}
+
+// WithOptionalHeaders makes the signer sign the headers only when the request
+// carries them, instead of failing. The headers must also be configured in
+// the header list of the signer; the headers parameter of the signature lists
+// the headers which were signed.
+func WithOptionalHeaders(headers ...string) Option {
+	return func(o *options) {
+		for _, header := range headers {
+			o.optionalHeaders = append(o.optionalHeaders, headerName(header))
+		}
+	}
+}
+
+// presentHeaders returns the headers without the optional headers missing
+// from the request
+func (o options) presentHeaders(r *http.Request, headers []string) []string {
+	var present []string
+	for _, header := range headers {
+		if requestHasHeader(r, header) || !contains(o.optionalHeaders, header) {
+			present = append(present, header)
+		}
+	}
+	return present
+}
+
+// WithSignatureHeaderName makes the signer add the signature to, and the
+// verification read it from, the named header instead of the Signature: or
+// Authorization: HTTP Header. The header holds the signature parameters
+// without an authorization scheme.
+func WithSignatureHeaderName(name string) Option {
+	return func(o *options) {
+		o.signatureHeader = name
+	}
+}
+
+// WithMaxDigestBodySize makes VerifyDigest read at most n bytes of the body,
+// larger bodies fail with ErrorBodyTooLargeForDigest. By default the body is
+// not limited.
+func WithMaxDigestBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxDigestBodySize = n
+	}
+}
+
+// WithExpectedDigest makes VerifyDigest compare the digest of algorithm, eg
+// "SHA-256", in the Digest: HTTP Header with value, the raw digest known out
+// of band, instead of digesting the body. The body is not read, so it can be
+// streamed.
+func WithExpectedDigest(algorithm string, value []byte) Option {
+	return func(o *options) {
+		o.expectedDigestAlgorithm = strings.ToLower(algorithm)
+		o.expectedDigest = value
+	}
+}
+
+// WithSkipDigestOnReEncoding makes VerifyDigest skip the digest comparison
+// when the Content-Encoding: HTTP Header is present and not identity, as a
+// proxy which compressed the body after signing changes the digest. The
+// signature still covers the Digest: HTTP Header, but the body is not
+// protected, so only use it behind proxies that re-encode bodies.
+func WithSkipDigestOnReEncoding() Option {
+	return func(o *options) {
+		o.skipDigestOnReEncoding = true
+	}
+}
+
+// KeySource returns the keyId and base64 encoded key to sign with
+type KeySource func() (keyID, keyB64 string, err error)
+
+// WithKeySource makes the signer fetch the key from source for every request
+// signed without a keyID and key, so keys can be rotated without creating a
+// new signer. The errors of source are returned by the signing methods.
+func WithKeySource(source KeySource) Option {
+	return func(o *options) {
+		o.keySource = source
+	}
+}
+
+// WithDecodedPath makes (request-target) use the percent-decoded path of the
+// request, eg /foo/bar for /foo%2Fbar, for compatibility with clients which
+// sign it. By default the path is used as sent. The path is never normalized
+// otherwise, so a trailing slash is significant. The signer and the
+// verification must agree on this setting, otherwise verification fails.
+func WithDecodedPath() Option {
+	return func(o *options) {
+		o.decodedPath = true
+	}
+}
+
+// WithRFC9421 makes the signer add a RFC 9421 signature in the Signature-Input
+// and Signature: HTTP Headers, and the verification read it from them, instead
+// of a Cavage signature. The headers of the signer are the covered components,
+// such as "@method", "@authority", "@path" and "date". Component parameters
+// are not supported. The signer always adds the created parameter. Signers
+// with an algorithm without RFC 9421 name, such as hmac-sha1, fail to sign.
+func WithRFC9421() Option {
+	return func(o *options) {
+		o.rfc9421 = true
+	}
+}
+
+// WithSignatureLabel makes the verification of RFC 9421 signatures verify the
+// signature with label, eg "sig2", instead of the first signature of the
+// Signature-Input header. The verification fails if the label is absent.
+func WithSignatureLabel(label string) Option {
+	return func(o *options) {
+		o.signatureLabel = label
+	}
+}
+
+// WithKeyIDFromHeader makes the verification look up the key by the value of
+// header instead of the keyId parameter. The header must be signed, so the
+// signature binds the keyId.
+func WithKeyIDFromHeader(header string) Option {
+	return func(o *options) {
+		o.keyIDHeader = header
+	}
+}
+
+// WithDateCreatedConsistency rejects signatures covering both the date, or
+// x-date, header and (created) when they are more than skew apart
+func WithDateCreatedConsistency(skew time.Duration) Option {
+	return func(o *options) {
+		o.dateCreatedSkew = skew
+	}
+}
+
+// WithPreservedHeaderWhitespace includes header values verbatim in the signing
+// string. By default the optional whitespace (spaces and tabs) around header
+// values is trimmed, as RFC 7230 does not make it part of the value. Both
+// sides must agree on this setting, otherwise verification fails.
+func WithPreservedHeaderWhitespace() Option {
+	return func(o *options) {
+		o.preserveWhitespace = true
+	}
+}
+
+// WithIncludeSigningStringInError adds the signing string computed by the
+// verification to the error when the signature does not verify, to debug
+// mismatches with a client. The signing string contains the signed header
+// values, so this should not be used in production.
+func WithIncludeSigningStringInError() Option {
+	return func(o *options) {
+		o.signingStringInError = true
+	}
+}
+
+// trimValue removes the optional whitespace around a header value, unless
+// whitespace is preserved
+func (o options) trimValue(value string) string {
+	if o.preserveWhitespace {
+		return value
+	}
+	return strings.Trim(value, " \t")
+}
+
+func (o options) notify(event VerifyEvent, sig SignatureParameters, err error) {
+	if o.verifyHook == nil {
+		return
+	}
+	o.verifyHook(event, sig.KeyID, sig.Algorithm.Name, err)
+}
+
+// SignatureEncoding is the encoding of the signature parameter
+type SignatureEncoding int
+
+const (
+	// EncodingBase64 encodes the signature as standard base64, as required by the
+	// draft. The verification also accepts base64url for clients which use it.
+	EncodingBase64 SignatureEncoding = iota
+	// EncodingHex encodes the signature as lowercase hex
+	EncodingHex
+)
+
+// WithSignatureEncoding sets the encoding of the signature parameter, both for
+// the signer and the verification. The default is EncodingBase64.
+func WithSignatureEncoding(encoding SignatureEncoding) Option {
+	return func(o *options) {
+		o.encoding = encoding
+	}
+}
+
+func (o options) encodeSignature(signature []byte) string {
+	if o.encoding == EncodingHex {
+		return hex.EncodeToString(signature)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func (o options) decodeSignature(signature string) ([]byte, error) {
+	if o.encoding == EncodingHex {
+		return hex.DecodeString(signature)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		// some clients encode the signature as base64url, with or without padding
+		if urlDecoded, urlErr := base64.RawURLEncoding.DecodeString(strings.TrimRight(signature, "=")); urlErr == nil {
+			return urlDecoded, nil
+		}
+	}
+	return decoded, err
+}
+
+// WithSigningStringLineEnding separates the lines of the signing string by
+// sep instead of "\n", for verifiers which expect eg "\r\n". Both the signer
+// and the verification must use this option. The RFC 9421 signature base
+// always uses "\n".
+func WithSigningStringLineEnding(sep string) Option {
+	return func(o *options) {
+		o.lineEnding = sep
+	}
+}
+
+// WithRawHMACKey uses the key of HMAC algorithms as the raw shared secret
+// instead of base64 decoding it. The keys of the other algorithms are still
+// base64 encoded.
+func WithRawHMACKey() Option {
+	return func(o *options) {
+		o.rawHMACKey = true
+	}
+}
+
+// decodeKey returns the key bytes for the algorithm
+func (o options) decodeKey(alg *Algorithm, key string) ([]byte, error) {
+	if o.rawHMACKey && alg.Kind == AlgorithmKindHMAC {
+		return []byte(key), nil
+	}
+	return decodeKey(key)
+}
@@ -0,0 +1,53 @@
+package httpsignatures
+
+import (
+	"net/http"
+	"net/http/httputil"
+)
+
+// SigningProxyConfig returns the key configuration used to sign requests
+// forwarded to host, host includes the port if the destination URL has one
+type SigningProxyConfig func(host string) (keyID, keyB64, algo string, headers []string, err error)
+
+// SigningProxyHandler returns a forward proxy handler which adds a http
+// signature to the Signature: HTTP Header of every request it forwards,
+// using the key configuration cfg returns for the destination host
+func SigningProxyHandler(cfg SigningProxyConfig) http.Handler {
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			if r.URL.Scheme == "" {
+				r.URL.Scheme = "http"
+			}
+			if r.URL.Host == "" {
+				r.URL.Host = r.Host
+			}
+		},
+		Transport: &signingTransport{cfg: cfg, next: http.DefaultTransport},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			httpErr, msg := ErrorToHTTPCode(err.Error())
+			if httpErr == http.StatusInternalServerError {
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			}
+			http.Error(w, msg, httpErr)
+		},
+	}
+}
+
+type signingTransport struct {
+	cfg  SigningProxyConfig
+	next http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	keyID, keyB64, algo, headers, err := t.cfg(r.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	if err := NewSigner(algo, headers...).SignRequest(r, keyID, keyB64); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(r)
+}
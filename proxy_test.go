@@ -0,0 +1,85 @@
+package httpsignatures_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func verifyingServer(algorithm string, key string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyLookUp := func(keyID string) (string, error) {
+			return key, nil
+		}
+		_, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{algorithm},
+			httpsignatures.HeaderRequestTarget, httpsignatures.HeaderHost)
+		if err != nil {
+			httpErr, msg := httpsignatures.ErrorToHTTPCode(err.Error())
+			http.Error(w, msg, httpErr)
+			return
+		}
+		w.Write([]byte(algorithm))
+	}))
+}
+
+func TestSigningProxyHandlerRoutesHostsToKeys(t *testing.T) {
+	hmacServer := verifyingServer(httpsignatures.AlgorithmHmacSha256, testKey)
+	defer hmacServer.Close()
+	ed25519Server := verifyingServer(httpsignatures.AlgorithmEd25519, ed25519TestPublicKey)
+	defer ed25519Server.Close()
+
+	hmacURL, _ := url.Parse(hmacServer.URL)
+	ed25519URL, _ := url.Parse(ed25519Server.URL)
+	headers := []string{"(request-target)", "host", "date"}
+	cfg := func(host string) (string, string, string, []string, error) {
+		switch host {
+		case hmacURL.Host:
+			return testKeyID, testKey, httpsignatures.AlgorithmHmacSha256, headers, nil
+		case ed25519URL.Host:
+			return testKeyID, ed25519TestPrivateKey, httpsignatures.AlgorithmEd25519, headers, nil
+		}
+		return "", "", "", nil, errors.New("no key configured for " + host)
+	}
+
+	proxy := httptest.NewServer(httpsignatures.SigningProxyHandler(cfg))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	for _, target := range []struct {
+		url       string
+		algorithm string
+	}{
+		{hmacServer.URL + "/foo?bar=baz", httpsignatures.AlgorithmHmacSha256},
+		{ed25519Server.URL + "/foo", httpsignatures.AlgorithmEd25519},
+	} {
+		r, err := http.NewRequest(http.MethodGet, target.url, nil)
+		assert.Nil(t, err)
+		r.Header.Set("Date", testDate)
+
+		resp, err := client.Do(r)
+		assert.Nil(t, err)
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, target.algorithm, string(body[:n]))
+	}
+
+	// unknown hosts are not forwarded
+	resp, err := client.Get("http://unknown.example.com/foo")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+	// signing errors are reported to the client
+	resp, err = client.Get(hmacServer.URL + "/foo")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,439 @@
+package httpsignatures
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// rfc9421Label is the signature label used for converted signatures
+	rfc9421Label = "sig1"
+)
+
+// rfc9421Algorithms maps the Cavage algorithm names to their RFC 9421
+// counterparts. Algorithms without a counterpart are not advertised.
+var rfc9421Algorithms = map[string]string{
+	AlgorithmHmacSha256:   "hmac-sha256",
+	AlgorithmEd25519:      "ed25519",
+	AlgorithmRsaSha256:    "rsa-v1_5-sha256",
+	AlgorithmRsaPssSha512: "rsa-pss-sha512",
+}
+
+// checkRFC9421Algorithm checks that the algorithm can be used for RFC 9421
+// signatures: it has a RFC 9421 name, or it is hs2019, which is signed without
+// alg parameter so the verifier derives the algorithm from the key
+func checkRFC9421Algorithm(alg *Algorithm) error {
+	if _, ok := rfc9421Algorithms[alg.Name]; ok || alg.Name == AlgorithmHs2019 {
+		return nil
+	}
+	return fmt.Errorf("%s: '%s'", ErrorAlgorithmNotSupportedByRFC9421, alg.Name)
+}
+
+// ConvertCavageToRFC9421 rewrites the Cavage signature of the request into the
+// RFC 9421 Signature-Input and Signature headers.
+//
+// Only the metadata is mapped: the covered headers become the covered
+// components, (created) and (expires) the created and expires parameters and
+// keyId/algorithm the keyid/alg parameters. The RFC 9421
+// signature base differs from the Cavage signing string, so the request must
+// be re-signed, eg by a signer using WithRFC9421, before the converted
+// signature will verify.
+func ConvertCavageToRFC9421(r *http.Request) error {
+	httpSignatureString, err := signatureStringFromRequest(r, options{})
+	if err != nil {
+		return err
+	}
+
+	var sig SignatureParameters
+	if err := sig.parseSignatureString(httpSignatureString, options{}); err != nil {
+		return err
+	}
+	sig.defaultHeaders(r)
+
+	var components []string
+	for _, header := range sig.HeaderList {
+		switch header {
+		case HeaderRequestTarget:
+			components = append(components, "@method", "@request-target")
+		case HeaderHost:
+			components = append(components, "@authority")
+		case HeaderCreated, HeaderExpires:
+			// the created and expires parameters are always covered
+		case HeaderProfile:
+			// the profile has no RFC 9421 counterpart
+		default:
+			components = append(components, header)
+		}
+	}
+	sig.HeaderList = components
+
+	input := rfc9421Label + "=" + sig.rfc9421SignatureParams()
+
+	if _, ok := r.Header["Signature"]; !ok {
+		r.Header.Del("Authorization")
+	}
+	r.Header.Set("Signature-Input", input)
+	r.Header.Set("Signature", fmt.Sprintf("%s=:%s:", rfc9421Label, sig.Signature))
+	return nil
+}
+
+// FromRequestRFC9421 takes the signature from the RFC 9421 Signature-Input and
+// Signature headers of the request, like FromRequest does for the Cavage
+// Signature header. The first signature of the Signature-Input header is used,
+// unless WithSignatureLabel selects another one, and the HeaderList holds its
+// covered components.
+//
+// Without an alg parameter the algorithm is hs2019, so it is derived from
+// the key, unless WithKeyAlgorithm binds the keyid to an algorithm.
+func (s *SignatureParameters) FromRequestRFC9421(r *http.Request) error {
+	return s.fromRequest(r, options{rfc9421: true})
+}
+
+// parseSignatureInput fills the parameters from the signature of the
+// WithSignatureLabel label, or else the first signature, of the
+// Signature-Input header and its value in the Signature header
+func (s *SignatureParameters) parseSignatureInput(r *http.Request, o options) error {
+	*s = SignatureParameters{}
+	input := strings.Join(r.Header.Values("Signature-Input"), ", ")
+	if input == "" {
+		return errors.New(ErrorNoSignatureHeaderFoundInRequest)
+	}
+
+	p := &sfParser{in: input}
+	var label, alg string
+	for {
+		var err error
+		label, alg, err = s.parseSignatureInputMember(p)
+		if err != nil {
+			return err
+		}
+		if o.signatureLabel == "" || label == o.signatureLabel {
+			break
+		}
+		p.skip(' ')
+		if !p.consume(',') {
+			return fmt.Errorf("%s '%s'", ErrorSignatureLabelNotFound, o.signatureLabel)
+		}
+		p.skip(' ')
+	}
+
+	if s.KeyID == "" && o.keyIDHeader == "" {
+		return errors.New(ErrorMissingSignatureParameterKeyId)
+	}
+	if err := s.rfc9421Algorithm(alg, o); err != nil {
+		return err
+	}
+
+	signature, ok := rfc9421Signature(strings.Join(r.Header.Values("Signature"), ","), label)
+	if !ok {
+		return errors.New(ErrorMissingSignatureParameterSignature)
+	}
+	s.Signature = signature
+	s.Headers = HeaderValues{}
+	return nil
+}
+
+// parseSignatureInputMember parses a member of the Signature-Input header into
+// the parameters, and returns its label and alg parameter
+func (s *SignatureParameters) parseSignatureInputMember(p *sfParser) (string, string, error) {
+	*s = SignatureParameters{}
+	label := p.key()
+	if label == "" || !p.consume('=') {
+		return "", "", errors.New(ErrorMalformedSignatureHeader)
+	}
+	start := p.pos
+	if err := s.parseInnerList(p); err != nil {
+		return "", "", err
+	}
+	var alg string
+	for p.consume(';') {
+		name := p.key()
+		if name == "" || !p.consume('=') {
+			return "", "", errors.New(ErrorMalformedSignatureHeader)
+		}
+		var err error
+		switch name {
+		case "created":
+			s.Created, err = p.integer()
+		case "expires":
+			s.Expires, err = p.integer()
+		case "keyid":
+			s.KeyID, err = p.str()
+		case "alg":
+			alg, err = p.str()
+		default:
+			// nonce and tag are covered by the signature, but not used here
+			_, err = p.bareItem()
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	s.signatureParams = p.in[start:p.pos]
+	return label, alg, nil
+}
+
+// parseInnerList parses the covered components into the header list
+func (s *SignatureParameters) parseInnerList(p *sfParser) error {
+	if !p.consume('(') {
+		return errors.New(ErrorMalformedSignatureHeader)
+	}
+	for {
+		p.skip(' ')
+		if p.consume(')') {
+			return nil
+		}
+		component, err := p.str()
+		if err != nil {
+			return err
+		}
+		if p.consume(';') {
+			return fmt.Errorf("%s '%s;%s'", ErrorUnsupportedSignatureComponent, component, p.key())
+		}
+		s.HeaderList = append(s.HeaderList, component)
+	}
+}
+
+// rfc9421Algorithm sets the algorithm from the alg parameter
+func (s *SignatureParameters) rfc9421Algorithm(alg string, o options) error {
+	if alg == "" {
+		if o.keyAlgorithm == nil {
+			s.Algorithm = algorithmHs2019
+			return nil
+		}
+		name, err := o.keyAlgorithm(s.KeyID)
+		if err != nil {
+			return err
+		}
+		s.Algorithm, err = algorithmFromString(name)
+		return err
+	}
+	for name, rfc9421Name := range rfc9421Algorithms {
+		if alg == rfc9421Name {
+			s.Algorithm = builtinAlgorithm(name)
+			return nil
+		}
+	}
+	return errorUnknownAlgorithm
+}
+
+// rfc9421Signature returns the base64 encoded signature of the label from
+// the Signature header
+func rfc9421Signature(header string, label string) (string, bool) {
+	for _, member := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if ok && name == label && len(value) > 1 && value[0] == ':' && value[len(value)-1] == ':' {
+			return value[1 : len(value)-1], true
+		}
+	}
+	return "", false
+}
+
+// rfc9421SignatureParams serializes the @signature-params of the signature
+func (s SignatureParameters) rfc9421SignatureParams() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, component := range s.HeaderList {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(quoteString(component))
+	}
+	b.WriteByte(')')
+	if s.Created != 0 {
+		fmt.Fprintf(&b, ";created=%d", s.Created)
+	}
+	if s.Expires != 0 {
+		fmt.Fprintf(&b, ";expires=%d", s.Expires)
+	}
+	fmt.Fprintf(&b, ";keyid=%s", quoteString(s.KeyID))
+	if alg, ok := rfc9421Algorithms[s.Algorithm.Name]; ok {
+		fmt.Fprintf(&b, ";alg=%s", quoteString(alg))
+	}
+	return b.String()
+}
+
+// signatureBase returns the RFC 9421 signature base of the covered components
+func (s SignatureParameters) signatureBase() string {
+	var b strings.Builder
+	for _, component := range s.HeaderList {
+		b.WriteString(quoteString(component))
+		b.WriteString(": ")
+		b.WriteString(s.Headers[component])
+		b.WriteByte('\n')
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(s.signatureParams)
+	return b.String()
+}
+
+// createdSigned reports whether the created parameter is covered by the
+// signature, which it always is for RFC 9421 signatures
+func (s SignatureParameters) createdSigned() bool {
+	return s.hasHeader(HeaderCreated) || s.isRFC9421()
+}
+
+// isRFC9421 reports whether the parameters are of a RFC 9421 signature
+func (s SignatureParameters) isRFC9421() bool {
+	return s.signatureParams != ""
+}
+
+// componentValue returns the value of a derived component, eg @method
+func componentValue(r *http.Request, component string) (string, error) {
+	if component == "@method" {
+		if len(r.Method) == 0 {
+			return "", errors.New(ErrorMethodNotInRequest)
+		}
+		return r.Method, nil
+	}
+	if r.URL == nil {
+		return "", errors.New(ErrorURLNotInRequest)
+	}
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := "?" + r.URL.RawQuery
+	target := path
+	if r.URL.RawQuery != "" {
+		target += query
+	}
+
+	switch component {
+	case "@authority":
+		return requestAuthority(r), nil
+	case "@scheme":
+		return requestScheme(r), nil
+	case "@path":
+		return path, nil
+	case "@query":
+		return query, nil
+	case "@request-target":
+		return target, nil
+	case "@target-uri":
+		return requestScheme(r) + "://" + requestAuthority(r) + target, nil
+	}
+	return "", fmt.Errorf("%s '%s'", ErrorUnsupportedSignatureComponent, component)
+}
+
+// requestScheme returns the lower case scheme of the request
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// requestAuthority returns the lower case host of the request, without the
+// default port of its scheme
+func requestAuthority(r *http.Request) string {
+	host := strings.ToLower(requestHost(r))
+	switch requestScheme(r) {
+	case "http":
+		return strings.TrimSuffix(host, ":80")
+	case "https":
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// quoteString serializes s as a structured field string
+func quoteString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sfParser parses the parts of structured fields used by Signature-Input
+type sfParser struct {
+	in  string
+	pos int
+}
+
+func (p *sfParser) peek() byte {
+	if p.pos < len(p.in) {
+		return p.in[p.pos]
+	}
+	return 0
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sfParser) skip(c byte) {
+	for p.consume(c) {
+	}
+}
+
+// key parses a key, which is lower case
+func (p *sfParser) key() string {
+	start := p.pos
+	for ; p.pos < len(p.in); p.pos++ {
+		c := p.in[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' && p.pos > start || strings.IndexByte("_-.*", c) >= 0) {
+			break
+		}
+	}
+	return p.in[start:p.pos]
+}
+
+// str parses a string
+func (p *sfParser) str() (string, error) {
+	if !p.consume('"') {
+		return "", errors.New(ErrorMalformedSignatureHeader)
+	}
+	var b strings.Builder
+	for p.pos < len(p.in) {
+		c := p.in[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos == len(p.in) {
+				return "", errors.New(ErrorMalformedSignatureHeader)
+			}
+			c = p.in[p.pos]
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+	return "", errors.New(ErrorMalformedSignatureHeader)
+}
+
+// integer parses a non-negative integer
+func (p *sfParser) integer() (int64, error) {
+	start := p.pos
+	for p.pos < len(p.in) && p.in[p.pos] >= '0' && p.in[p.pos] <= '9' {
+		p.pos++
+	}
+	value, err := strconv.ParseInt(p.in[start:p.pos], 10, 64)
+	if err != nil {
+		return 0, errors.New(ErrorMalformedSignatureHeader)
+	}
+	return value, nil
+}
+
+// bareItem skips a string, integer or token
+func (p *sfParser) bareItem() (string, error) {
+	if p.peek() == '"' {
+		return p.str()
+	}
+	start := p.pos
+	for p.pos < len(p.in) && strings.IndexByte(" ,;()", p.in[p.pos]) < 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", errors.New(ErrorMalformedSignatureHeader)
+	}
+	return p.in[start:p.pos], nil
+}
@@ -0,0 +1,344 @@
+package httpsignatures_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func TestConvertCavageToRFC9421(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo?param=value", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.AuthRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+
+	err = httpsignatures.ConvertCavageToRFC9421(r)
+	assert.Nil(t, err)
+	assert.Empty(t, r.Header.Get("Authorization"))
+	assert.Equal(t, `sig1=("@method" "@request-target" "@authority" "date");keyid="Test";alg="hmac-sha256"`,
+		r.Header.Get("Signature-Input"))
+	assert.Equal(t, "sig1=:"+s.Signature+":", r.Header.Get("Signature"))
+}
+
+func TestConvertCavageToRFC9421FromSignatureHeader(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha1Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	err = httpsignatures.ConvertCavageToRFC9421(r)
+	assert.Nil(t, err)
+	// hmac-sha1 has no RFC 9421 counterpart, so no alg parameter is emitted
+	assert.Equal(t, `sig1=("date");keyid="Test"`, r.Header.Get("Signature-Input"))
+	assert.Equal(t, "sig1=:"+testSha1Hash+":", r.Header.Get("Signature"))
+}
+
+func TestConvertCavageToRFC9421CreatedExpires(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	r.Header.Set("Signature", `keyId="key\1",algorithm="hmac-sha256",created=1325799100,expires=1325799400,`+
+		`headers="(request-target) (created) (expires) date",signature="`+testSha256Hash+`"`)
+
+	err = httpsignatures.ConvertCavageToRFC9421(r)
+	assert.Nil(t, err)
+	assert.Equal(t, `sig1=("@method" "@request-target" "date");created=1325799100;expires=1325799400;`+
+		`keyid="key\\1";alg="hmac-sha256"`, r.Header.Get("Signature-Input"))
+}
+
+func TestConvertCavageToRFC9421WithoutSignature(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+
+	err := httpsignatures.ConvertCavageToRFC9421(r)
+	assert.EqualError(t, err, httpsignatures.ErrorNoSignatureHeaderFoundInRequest)
+}
+
+// the examples of RFC 9421 appendix B.2
+const (
+	rfc9421SharedSecret   = "uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ=="
+	rfc9421Ed25519Public  = "JrQLj5P/89iXES9+vFgrIy29clF9CC/oPPsw3c5D0bs="
+	rfc9421Ed25519Private = "n4Ni+HpISpVObnQMW0wOhCKROaIKqKtW/2ZYb2p9KcUmtAuPk//z2JcRL368WCsjLb1yUX0IL+g8+zDdzkPRuw=="
+	rfc9421Created        = 1618884473
+)
+
+func rfc9421ExampleRequest(t *testing.T) *http.Request {
+	r, err := http.NewRequest(http.MethodPost, "http://example.com/foo?param=Value&Pet=dog", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+	r.Header.Set("Content-Length", "18")
+	return r
+}
+
+func rfc9421KeyLookUp(keyID string) (string, error) {
+	switch keyID {
+	case "test-shared-secret":
+		return rfc9421SharedSecret, nil
+	case "test-key-ed25519":
+		return rfc9421Ed25519Public, nil
+	}
+	return "", errors.New(httpsignatures.ErrorUnknownKeyID)
+}
+
+func TestVerifyRFC9421HmacExample(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	r.Header.Set("Signature-Input", `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`)
+	r.Header.Set("Signature", `sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`)
+
+	// without alg parameter the algorithm of the shared secret must be known
+	keyAlgorithms := httpsignatures.WithKeyAlgorithmMap(map[string]string{
+		"test-shared-secret": httpsignatures.AlgorithmHmacSha256,
+	})
+
+	var s httpsignatures.SignatureParameters
+	err := s.FromRequestRFC9421(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-shared-secret", s.KeyID)
+	assert.Equal(t, []string{"date", "@authority", "content-type"}, s.HeaderList)
+	assert.Equal(t, "example.com", s.Headers["@authority"])
+	assert.Equal(t, int64(rfc9421Created), s.Created)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, []string{"@authority"}, httpsignatures.WithRFC9421(), keyAlgorithms)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Set("Content-Type", "text/plain")
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithRFC9421(), keyAlgorithms)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestVerifyRFC9421Ed25519Example(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	r.Header.Set("Signature-Input", `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");`+
+		`created=1618884473;keyid="test-key-ed25519"`)
+	r.Header.Set("Signature", `sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`)
+
+	// the algorithm is derived from the key
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421())
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the created parameter is the signing time
+	created := time.Unix(rfc9421Created, 0)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, 300,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421(),
+		httpsignatures.WithFreshnessSource(httpsignatures.FreshnessSourceCreated),
+		httpsignatures.WithClock(func() time.Time { return created.Add(301 * time.Second) }))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+}
+
+func TestVerifyRFC9421SignatureLabel(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	r.Header.Set("Signature-Input", `sig1=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret", `+
+		`sig2=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`)
+	r.Header.Set("Signature", `sig1=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:, `+
+		`sig2=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421(), httpsignatures.WithSignatureLabel("sig2"))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421(), httpsignatures.WithSignatureLabel("sig3"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignatureLabelNotFound+" 'sig3'")
+}
+
+func TestSignRFC9421(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	created := time.Unix(rfc9421Created, 0)
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmEd25519,
+		[]string{"@method", "@target-uri", "@query", "date", "content-type"}, httpsignatures.WithRFC9421(),
+		httpsignatures.WithClock(func() time.Time { return created }))
+	err := signer.SignRequest(r, "test-key-ed25519", rfc9421Ed25519Private)
+	assert.Nil(t, err)
+	assert.Equal(t, `sig1=("@method" "@target-uri" "@query" "date" "content-type");created=1618884473;`+
+		`keyid="test-key-ed25519";alg="ed25519"`, r.Header.Get("Signature-Input"))
+	assert.Regexp(t, `^sig1=:[A-Za-z0-9+/]+=*:$`, r.Header.Get("Signature"))
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequestRFC9421(r)
+	assert.Nil(t, err)
+	assert.Equal(t, httpsignatures.AlgorithmEd25519, s.Algorithm.Name)
+	assert.Equal(t, "http://example.com/foo?param=Value&Pet=dog", s.Headers["@target-uri"])
+	assert.Equal(t, "?param=Value&Pet=dog", s.Headers["@query"])
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmEd25519}, []string{"@method", "@target-uri"}, httpsignatures.WithRFC9421())
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the Cavage verification does not read RFC 9421 signatures
+	r.Header.Del("Signature-Input")
+	res, err = httpsignatures.VerifyRequest(r, rfc9421KeyLookUp, -1, []string{httpsignatures.AlgorithmEd25519})
+	assert.False(t, res)
+	assert.NotNil(t, err)
+}
+
+func TestSignRFC9421EmptyQuery(t *testing.T) {
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmEd25519,
+		[]string{"@method", "@path", "@query"}, httpsignatures.WithRFC9421())
+	for _, url := range []string{"http://example.com/foo", "http://example.com/foo?"} {
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.Nil(t, err)
+		err = signer.SignRequest(r, "test-key-ed25519", rfc9421Ed25519Private)
+		assert.Nil(t, err, url)
+
+		var s httpsignatures.SignatureParameters
+		err = s.FromRequestRFC9421(r)
+		assert.Nil(t, err, url)
+		assert.Equal(t, "/foo", s.Headers["@path"], url)
+		assert.Equal(t, "?", s.Headers["@query"], url)
+
+		res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+			[]string{httpsignatures.AlgorithmEd25519}, []string{"@query"}, httpsignatures.WithRFC9421())
+		assert.True(t, res, url)
+		assert.Nil(t, err, url)
+
+		// a query added in transit breaks the signature
+		r.URL.RawQuery = "param=value"
+		res, err = httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+			[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421())
+		assert.False(t, res, url)
+		assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch, url)
+	}
+}
+
+func TestRFC9421DefaultPorts(t *testing.T) {
+	tests := []struct {
+		url       string
+		authority string
+		targetURI string
+	}{
+		{"http://example.com/foo", "example.com", "http://example.com/foo"},
+		{"http://example.com:80/foo", "example.com", "http://example.com/foo"},
+		{"http://example.com:443/foo", "example.com:443", "http://example.com:443/foo"},
+		{"https://example.com/foo", "example.com", "https://example.com/foo"},
+		{"https://example.com:443/foo", "example.com", "https://example.com/foo"},
+		{"https://Example.com:8443/foo", "example.com:8443", "https://example.com:8443/foo"},
+	}
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmEd25519,
+		[]string{"@authority", "@target-uri"}, httpsignatures.WithRFC9421())
+	for _, test := range tests {
+		r, err := http.NewRequest(http.MethodGet, test.url, nil)
+		assert.Nil(t, err)
+		err = signer.SignRequest(r, "test-key-ed25519", rfc9421Ed25519Private)
+		assert.Nil(t, err, test.url)
+
+		var s httpsignatures.SignatureParameters
+		err = s.FromRequestRFC9421(r)
+		assert.Nil(t, err, test.url)
+		assert.Equal(t, test.authority, s.Headers["@authority"], test.url)
+		assert.Equal(t, test.targetURI, s.Headers["@target-uri"], test.url)
+
+		// the verifier may see the host with or without the default port
+		r.Host = test.authority
+		res, err := httpsignatures.VerifyRequestWithOptions(r, rfc9421KeyLookUp, -1,
+			[]string{httpsignatures.AlgorithmEd25519}, nil, httpsignatures.WithRFC9421())
+		assert.True(t, res, test.url)
+		assert.Nil(t, err, test.url)
+	}
+}
+
+func TestSignRFC9421RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privateKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	publicKey := base64.StdEncoding.EncodeToString(der)
+	rsaKeyLookUp := func(keyID string) (string, error) {
+		return publicKey, nil
+	}
+
+	for algorithm, alg := range map[string]string{
+		httpsignatures.AlgorithmRsaSha256:    "rsa-v1_5-sha256",
+		httpsignatures.AlgorithmRsaPssSha512: "rsa-pss-sha512",
+	} {
+		r := rfc9421ExampleRequest(t)
+		signer := httpsignatures.NewSignerWithOptions(algorithm, []string{"@method", "@authority", "date"},
+			httpsignatures.WithRFC9421())
+		err := signer.SignRequest(r, "test-key-rsa", privateKey)
+		assert.Nil(t, err)
+		assert.Contains(t, r.Header.Get("Signature-Input"), `;alg="`+alg+`"`)
+
+		res, err := httpsignatures.VerifyRequestWithOptions(r, rsaKeyLookUp, -1, []string{algorithm}, nil,
+			httpsignatures.WithRFC9421())
+		assert.True(t, res, algorithm)
+		assert.Nil(t, err, algorithm)
+	}
+}
+
+func TestSignRFC9421UnsupportedAlgorithm(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha1, nil, httpsignatures.WithRFC9421())
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotSupportedByRFC9421+": 'hmac-sha1'")
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+}
+
+func TestSignRFC9421Profile(t *testing.T) {
+	r := rfc9421ExampleRequest(t)
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha256, nil,
+		httpsignatures.WithRFC9421(), httpsignatures.WithProfile("payments"))
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorProfileNotSupportedByRFC9421)
+	assert.Empty(t, r.Header.Get("Signature-Input"))
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+}
+
+func TestFromRequestRFC9421Errors(t *testing.T) {
+	for input, expected := range map[string]string{
+		``:                                    httpsignatures.ErrorNoSignatureHeaderFoundInRequest,
+		`sig1="date";keyid="a"`:               httpsignatures.ErrorMalformedSignatureHeader,
+		`sig1=("date" "@authority"`:           httpsignatures.ErrorMalformedSignatureHeader,
+		`sig1=("date");created=now;keyid="a"`: httpsignatures.ErrorMalformedSignatureHeader,
+		`sig1=("date")`:                       httpsignatures.ErrorMissingSignatureParameterKeyId,
+		`sig1=("date");keyid="a";alg="ecdsa-p256-sha256"`: httpsignatures.ErrorUnknownAlgorithm,
+		`sig1=("date" "@status");keyid="a"`:               httpsignatures.ErrorUnsupportedSignatureComponent + " '@status'",
+		`sig1=("date" "example-dict";sf);keyid="a"`: httpsignatures.ErrorUnsupportedSignatureComponent +
+			" 'example-dict;sf'",
+		`sig2=("date");keyid="a"`: httpsignatures.ErrorMissingSignatureParameterSignature,
+	} {
+		r := rfc9421ExampleRequest(t)
+		if input != "" {
+			r.Header.Set("Signature-Input", input)
+		}
+		r.Header.Set("Signature", "sig1=:c2lnbmF0dXJl:")
+
+		var s httpsignatures.SignatureParameters
+		err := s.FromRequestRFC9421(r)
+		assert.EqualError(t, err, expected, input)
+	}
+}
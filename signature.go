@@ -7,8 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type SignatureParameters struct {
@@ -17,6 +21,17 @@ type SignatureParameters struct {
 	Headers    HeaderValues
 	HeaderList []string
 	Signature  string
+	// Profile optionally names the signing profile, so the verifier can select
+	// its validation rules. It is covered by the signature when the (profile)
+	// pseudo header is signed.
+	Profile string
+	// Created and Expires are the created and expires parameters as unix
+	// timestamps, 0 when absent
+	Created int64
+	Expires int64
+	// signatureParams is the serialized @signature-params of a RFC 9421
+	// signature, empty for Cavage signatures
+	signatureParams string
 }
 
 const (
@@ -24,42 +39,135 @@ const (
 	HeaderDate          string = "date"
 	HeaderXDate         string = "x-date"
 	HeaderHost          string = "host"
+	HeaderCreated       string = "(created)"
+	HeaderExpires       string = "(expires)"
+	HeaderProfile       string = "(profile)"
+	// HeaderQueryParam prefixes the name of a single signed query parameter,
+	// eg `(query-param);name=pet`
+	HeaderQueryParam string = "(query-param);name="
 )
 
+// DefaultSensitiveHeaders are the headers that can be used for header smuggling
+// when present but not signed, for use with WithSignedIfPresent
+var DefaultSensitiveHeaders = []string{"content-length", "content-type", HeaderHost}
+
 // FromRequest takes the signature string from the HTTP-Request
 // both Signature and Authorization http headers are supported.
+// When a request carries both, the Signature header is used. The Signature
+// scheme of the Authorization header is case insensitive, and whitespace
+// around the parameters and between the headers is ignored.
 func (s *SignatureParameters) FromRequest(r *http.Request) error {
-	var httpSignatureString string
-	if sig, ok := r.Header["Signature"]; ok {
-		httpSignatureString = sig[0]
+	return s.fromRequest(r, options{})
+}
+
+func (s *SignatureParameters) fromRequest(r *http.Request, o options) error {
+	if o.rfc9421 {
+		if err := s.parseSignatureInput(r, o); err != nil {
+			return err
+		}
 	} else {
-		if h, ok := r.Header["Authorization"]; ok {
-			httpSignatureString = strings.TrimPrefix(h[0], "Signature ")
-		} else {
-			return errors.New(ErrorNoSignatureHeaderFoundInRequest)
+		httpSignatureString, err := signatureStringFromRequest(r, o)
+		if err != nil {
+			return err
 		}
+		if err := s.parseSignatureString(httpSignatureString, o); err != nil {
+			return err
+		}
+		s.defaultHeaders(r)
 	}
-	if err := s.parseSignatureString(httpSignatureString); err != nil {
+	if err := s.parseRequest(r, o); err != nil {
 		return err
 	}
-	if err := s.ParseRequest(r); err != nil {
-		return err
+
+	if o.keyIDHeader != "" {
+		if !s.hasHeader(o.keyIDHeader) {
+			return fmt.Errorf("%s: '%s'", ErrorKeyIDHeaderNotSigned, o.keyIDHeader)
+		}
+		s.KeyID = s.Headers[headerName(o.keyIDHeader)]
 	}
 
 	// todo: check if all required headers are available
 	return nil
 }
 
+// defaultHeaders sets the header list of a signature without headers
+// parameter to the date header, or the x-date header when the request only
+// has that one
+func (s *SignatureParameters) defaultHeaders(r *http.Request) {
+	if len(s.HeaderList) > 0 {
+		return
+	}
+	if !requestHasHeader(r, HeaderDate) && requestHasHeader(r, HeaderXDate) {
+		s.HeaderList = []string{HeaderXDate}
+	} else {
+		s.HeaderList = []string{HeaderDate}
+	}
+	s.Headers = HeaderValues{}
+}
+
+// hasSignatureScheme reports whether the Authorization header value uses the
+// Signature scheme, which is case insensitive
+func hasSignatureScheme(value string) bool {
+	value = strings.TrimLeft(value, " \t")
+	return len(value) > len("Signature") && strings.EqualFold(value[:len("Signature")], "Signature") &&
+		(value[len("Signature")] == ' ' || value[len("Signature")] == '\t')
+}
+
+// trimSignatureScheme removes the Signature scheme and surrounding whitespace
+// from the Authorization header value
+func trimSignatureScheme(value string) string {
+	if !hasSignatureScheme(value) {
+		return value
+	}
+	return strings.TrimSpace(strings.TrimLeft(value, " \t")[len("Signature"):])
+}
+
+// signatureStringFromRequest returns the signature parameters string from
+// the Signature header, or from the Authorization header if there is none
+// or WithAuthorizationPrecedence is set. With WithSignatureHeaderName only
+// the named header is used.
+func signatureStringFromRequest(r *http.Request, o options) (string, error) {
+	if o.signatureHeader != "" {
+		if sig := r.Header.Get(o.signatureHeader); sig != "" {
+			return sig, nil
+		}
+		return "", fmt.Errorf("%s: '%s'", ErrorNoSignatureHeaderFoundInRequest, o.signatureHeader)
+	}
+	if sig, ok := r.Header["Signature"]; ok {
+		if h, ok := r.Header["Authorization"]; ok && hasSignatureScheme(h[0]) {
+			auth := trimSignatureScheme(h[0])
+			if o.rejectConflictingSignatures && auth != sig[0] {
+				return "", errors.New(ErrorConflictingSignatureHeaders)
+			}
+			if o.authorizationPrecedence {
+				return auth, nil
+			}
+		}
+	}
+	if sig, ok := r.Header["Signature"]; ok {
+		return sig[0], nil
+	}
+	if h, ok := r.Header["Authorization"]; ok {
+		return trimSignatureScheme(h[0]), nil
+	}
+	return "", errors.New(ErrorNoSignatureHeaderFoundInRequest)
+}
+
 // FromConfig takes the string configuration and fills the
 // SignatureParameters struct
 func (s *SignatureParameters) FromConfig(keyId string, algorithm string, headers []string) error {
 	if len(keyId) == 0 {
 		return errors.New(ErrorNoKeyIDConfigured)
 	}
+	s.KeyID = keyId
+	return s.configure(algorithm, headers)
+}
+
+// configure fills the algorithm and header list of the configuration
+func (s *SignatureParameters) configure(algorithm string, headers []string) error {
 	if len(algorithm) == 0 {
 		return errors.New(ErrorNoAlgorithmConfigured)
 	}
-	s.KeyID = keyId
 
 	alg, err := algorithmFromString(algorithm)
 	if err != nil {
@@ -72,7 +180,14 @@ func (s *SignatureParameters) FromConfig(keyId string, algorithm string, headers
 		s.Headers = HeaderValues{}
 	} else {
 		s.Headers = HeaderValues{}
+		seen := map[string]bool{}
 		for _, header := range headers {
+			// the header names are emitted in lower case, so they are signed in lower case
+			header = headerName(header)
+			if seen[header] {
+				return fmt.Errorf("%s '%s'", ErrorDuplicateHeader, header)
+			}
+			seen[header] = true
 			s.HeaderList = append(s.HeaderList, header)
 		}
 	}
@@ -83,6 +198,10 @@ func (s *SignatureParameters) FromConfig(keyId string, algorithm string, headers
 // ParseRequest extracts the header fields from the request required
 // by the `headers` parameter in the configuration
 func (s *SignatureParameters) ParseRequest(r *http.Request) error {
+	return s.parseRequest(r, options{})
+}
+
+func (s *SignatureParameters) parseRequest(r *http.Request, o options) error {
 	if len(s.HeaderList) == 0 {
 		return errors.New(ErrorNoHeadersConfigLoaded)
 	}
@@ -90,46 +209,99 @@ func (s *SignatureParameters) ParseRequest(r *http.Request) error {
 		s.Headers = HeaderValues{}
 	}
 	for _, header := range s.HeaderList {
-		switch header {
-		case "(request-target)":
-			if tl, err := requestTargetLine(r); err == nil {
-				s.Headers[header] = strings.TrimSpace(tl)
-			} else {
-				return err
-			}
-		case "host":
-			if host := r.Host; host != "" {
-				s.Headers[header] = strings.TrimSpace(host)
-			} else {
-				return errors.New(ErrorMissingRequiredHeader + " 'host'")
-			}
-		default:
-			// If there are multiple headers with the same name, add them all.
-			if len(r.Header[http.CanonicalHeaderKey(header)]) > 0 {
-				var trimmedValues []string
-				for _, value := range r.Header[http.CanonicalHeaderKey(header)] {
-					trimmedValues = append(trimmedValues, strings.TrimSpace(value))
-				}
-				s.Headers[header] = strings.Join(trimmedValues, ", ")
-			} else {
-				return fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
-			}
+		value, err := s.signedValue(r, header, o)
+		if err != nil {
+			return err
 		}
+		s.Headers[header] = value
 	}
 	return nil
 }
 
+// signedValue returns the value of the header as used in the signing string,
+// taking the (created) and (expires) pseudo headers from the parameters
+func (s SignatureParameters) signedValue(r *http.Request, header string, o options) (string, error) {
+	switch header {
+	case HeaderCreated:
+		if s.Created == 0 {
+			return "", errors.New(ErrorMissingCreatedParameter)
+		}
+		return strconv.FormatInt(s.Created, 10), nil
+	case HeaderExpires:
+		if s.Expires == 0 {
+			return "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
+		}
+		return strconv.FormatInt(s.Expires, 10), nil
+	case HeaderProfile:
+		if s.Profile == "" {
+			return "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
+		}
+		return s.Profile, nil
+	}
+	return headerValue(r, header, o)
+}
+
+// headerValue returns the value of the header in the request as used
+// in the signing string
+func headerValue(r *http.Request, header string, o options) (string, error) {
+	switch header {
+	case "(request-target)":
+		tl, err := requestTargetLine(r, o)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(tl), nil
+	case "host":
+		if host := requestHost(r); host != "" {
+			return strings.TrimSpace(host), nil
+		}
+		return "", errors.New(ErrorMissingRequiredHeader + " 'host'")
+	default:
+		if isQueryParam(header) {
+			return queryParamValue(r, header)
+		}
+		if strings.HasPrefix(header, "@") {
+			return componentValue(r, header)
+		}
+		// If there are multiple headers with the same name, add them all.
+		if len(r.Header[http.CanonicalHeaderKey(header)]) > 0 {
+			var trimmedValues []string
+			for _, value := range r.Header[http.CanonicalHeaderKey(header)] {
+				trimmedValues = append(trimmedValues, o.trimValue(value))
+			}
+			return strings.Join(trimmedValues, ", "), nil
+		}
+		return "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
+	}
+}
+
 // FromString creates a new Signature from its encoded form,
 // eg `keyId="a",algorithm="b",headers="c",signature="d"`
-func (s *SignatureParameters) parseSignatureString(in string) error {
+func (s *SignatureParameters) parseSignatureString(in string, o options) error {
 	var key, value string
 	*s = SignatureParameters{}
-	signatureRegex := regexp.MustCompile(`(\w+)="([^"]*)"`)
+	signatureRegex := regexp.MustCompile(`(\w+)=(?:"([^"]*)"|(\d+))`)
 
 	for _, m := range signatureRegex.FindAllStringSubmatch(in, -1) {
 		key = m[1]
 		value = m[2]
 
+		// created and expires are unquoted integers, but some clients quote them
+		if m[3] != "" || key == "created" || key == "expires" {
+			timestamp, err := strconv.ParseInt(m[2]+m[3], 10, 64)
+			if err != nil {
+				return errors.New(ErrorMalformedSignatureHeader)
+			}
+			if key == "created" {
+				s.Created = timestamp
+			} else if key == "expires" {
+				s.Expires = timestamp
+			} else if o.strictParameters {
+				return fmt.Errorf("%s '%s'", ErrorUnknownSignatureParameter, key)
+			}
+			continue
+		}
+
 		if key == "keyId" {
 			s.KeyID = value
 		} else if key == "algorithm" {
@@ -142,20 +314,20 @@ func (s *SignatureParameters) parseSignatureString(in string) error {
 			s.ParseString(value)
 		} else if key == "signature" {
 			s.Signature = value
+		} else if key == "profile" {
+			s.Profile = value
+		} else if o.strictParameters {
+			return fmt.Errorf("%s '%s'", ErrorUnknownSignatureParameter, key)
 		}
 		// ignore unknown parameters
 	}
 
-	if len(s.HeaderList) == 0 {
-		s.HeaderList = []string{"date"}
-		s.Headers = HeaderValues{}
-	}
-
 	if len(s.Signature) == 0 {
 		return errors.New(ErrorMissingSignatureParameterSignature)
 	}
 
-	if len(s.KeyID) == 0 {
+	// the keyId may be passed in a header instead, see WithKeyIDFromHeader
+	if len(s.KeyID) == 0 && o.keyIDHeader == "" {
 		return errors.New(ErrorMissingSignatureParameterKeyId)
 	}
 
@@ -163,9 +335,50 @@ func (s *SignatureParameters) parseSignatureString(in string) error {
 		return errors.New(ErrorMissingSignatureParameterAlgorithm)
 	}
 
+	// a signed (created) needs the parameter it takes its value from
+	if s.Created == 0 && s.hasHeader(HeaderCreated) {
+		return errors.New(ErrorMissingCreatedParameter)
+	}
+
 	return nil
 }
 
+var signatureFormatRegex = regexp.MustCompile(`^\s*\w+=("[^"]*"|\d+)(\s*,\s*\w+=("[^"]*"|\d+))*\s*$`)
+
+// ValidateAuthorizationHeader checks that the value of an Authorization or
+// Signature header is well-formed: a comma separated list of quoted
+// parameters including keyId, a known algorithm and signature. The signature
+// itself is not verified.
+func ValidateAuthorizationHeader(value string) error {
+	value = trimSignatureScheme(value)
+	if !signatureFormatRegex.MatchString(value) {
+		return errors.New(ErrorMalformedSignatureHeader)
+	}
+
+	var s SignatureParameters
+	return s.parseSignatureString(value, options{})
+}
+
+// AuthorizationHeadersEquivalent reports whether two Authorization or
+// Signature header values carry the same signature parameters, regardless of
+// the order of the parameters and the whitespace around them. The order of
+// the signed headers is significant, so it must be the same. Values which can
+// not be parsed are never equivalent.
+func AuthorizationHeadersEquivalent(a, b string) bool {
+	var sa, sb SignatureParameters
+	if sa.parseSignatureString(trimSignatureScheme(a), options{}) != nil ||
+		sb.parseSignatureString(trimSignatureScheme(b), options{}) != nil {
+		return false
+	}
+	return sa.KeyID == sb.KeyID &&
+		sa.Algorithm.Name == sb.Algorithm.Name &&
+		strings.Join(sa.HeaderList, " ") == strings.Join(sb.HeaderList, " ") &&
+		sa.Signature == sb.Signature &&
+		sa.Created == sb.Created &&
+		sa.Expires == sb.Expires &&
+		sa.Profile == sb.Profile
+}
+
 // String returns the encoded form of the Signature
 func (s SignatureParameters) hTTPSignatureString(signature string) string {
 	str := fmt.Sprintf(
@@ -174,56 +387,163 @@ func (s SignatureParameters) hTTPSignatureString(signature string) string {
 		s.Algorithm.Name,
 	)
 
+	if s.Created != 0 {
+		str += fmt.Sprintf(`,created=%d`, s.Created)
+	}
+	if s.Expires != 0 {
+		str += fmt.Sprintf(`,expires=%d`, s.Expires)
+	}
+
 	if len(s.HeaderList) > 0 {
 		str += fmt.Sprintf(`,headers="%s"`, s.toHeadersString())
 	}
 
+	if len(s.Profile) > 0 {
+		str += fmt.Sprintf(`,profile="%s"`, s.Profile)
+	}
+
 	str += fmt.Sprintf(`,signature="%s"`, signature)
 
 	return str
 }
 
-func (s SignatureParameters) calculateSignature(keyB64 string) (string, error) {
-	signingString, err := s.signingString()
+func (s SignatureParameters) calculateSignature(keyB64 string, o options) (string, error) {
+	signingString, err := s.signingString(o)
 	if err != nil {
 		return "", err
 	}
-	byteKey, err := base64.StdEncoding.DecodeString(keyB64)
+	return signBytes(s.Algorithm, keyB64, []byte(signingString), o)
+}
+
+// Verify verifies this signature for the given base64 encodedkey
+func (s SignatureParameters) Verify(keyBase64 string) (bool, error) {
+	return s.verify(keyBase64, options{})
+}
+
+func (s SignatureParameters) verify(keyBase64 string, o options) (bool, error) {
+	signingString, err := s.signingString(o)
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	valid, err := verifyBytes(s.Algorithm, keyBase64, s.Signature, []byte(signingString), o)
+	if err != nil && o.signingStringInError {
+		return valid, fmt.Errorf("%s, signing string %q", err, signingString)
+	}
+	return valid, err
+}
+
+// VerifyWith verifies the signature parameters, as parsed by FromRequest, with
+// the key. The signed header values are read from r again, the Signature header
+// is not parsed. Unlike VerifyRequest it does not check the algorithm, the
+// required headers or the clock skew, callers must check those themselves.
+func (s SignatureParameters) VerifyWith(keyB64 string, r *http.Request) (bool, error) {
+	if s.Algorithm == nil {
+		return false, errors.New(ErrorMissingSignatureParameterAlgorithm)
+	}
+	if err := s.parseRequest(r, options{}); err != nil {
+		return false, err
+	}
+	if s.Algorithm.Name == AlgorithmHs2019 {
+		if err := s.resolveAlgorithm(keyB64); err != nil {
+			return false, err
+		}
 	}
+	return s.verify(keyB64, options{})
+}
 
-	signature, err := s.Algorithm.Sign(&byteKey, []byte(signingString))
+// ComputeSignature signs the header values in params.Headers without a
+// http.Request, for generating fixtures and comparing implementations.
+// params.HeaderList sets the signed headers and their order, pseudo headers
+// such as (request-target) must be given as their signing string value.
+// It returns the signing string and the Signature header value.
+func ComputeSignature(params SignatureParameters, keyB64 string) (signingString, header string, err error) {
+	if len(params.KeyID) == 0 {
+		return "", "", errors.New(ErrorNoKeyIDConfigured)
+	}
+	if params.Algorithm == nil {
+		return "", "", errors.New(ErrorNoAlgorithmConfigured)
+	}
+	if len(params.HeaderList) == 0 {
+		return "", "", errors.New(ErrorNoHeadersConfigLoaded)
+	}
+	for _, h := range params.HeaderList {
+		if _, ok := params.Headers[h]; !ok {
+			return "", "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, h)
+		}
+	}
+
+	signingString, err = params.signingString(options{})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	signature, err := signBytes(params.Algorithm, keyB64, []byte(signingString), options{})
+	if err != nil {
+		return "", "", err
+	}
+	return signingString, params.hTTPSignatureString(signature), nil
+}
 
-	return base64.StdEncoding.EncodeToString(*signature), err
+// SignBytes signs data with the algorithm and the base64 encoded key, and
+// returns the base64 encoded signature
+func SignBytes(algorithm, keyB64 string, data []byte) (string, error) {
+	alg, err := algorithmFromString(algorithm)
+	if err != nil {
+		return "", err
+	}
+	return signBytes(alg, keyB64, data, options{})
 }
 
-// Verify verifies this signature for the given base64 encodedkey
-func (s SignatureParameters) Verify(keyBase64 string) (bool, error) {
-	signingString, err := s.signingString()
+// VerifyBytes verifies the base64 encoded signature of data with the
+// algorithm and the base64 encoded key
+func VerifyBytes(algorithm, keyB64, signatureB64 string, data []byte) (bool, error) {
+	alg, err := algorithmFromString(algorithm)
 	if err != nil {
 		return false, err
 	}
+	return verifyBytes(alg, keyB64, signatureB64, data, options{})
+}
+
+func signBytes(alg *Algorithm, keyB64 string, data []byte, o options) (string, error) {
+	byteKey, err := o.decodeKey(alg, keyB64)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSigningKey(alg, byteKey); err != nil {
+		return "", err
+	}
 
-	byteKey, err := base64.StdEncoding.DecodeString(keyBase64)
+	signature, err := alg.Sign(&byteKey, data)
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	byteSignature, err := base64.StdEncoding.DecodeString(s.Signature)
+	return o.encodeSignature(*signature), nil
+}
+
+func verifyBytes(alg *Algorithm, keyB64, signature string, data []byte, o options) (bool, error) {
+	byteKey, err := o.decodeKey(alg, keyB64)
 	if err != nil {
 		return false, err
 	}
+	if err := checkVerifyingKey(alg, byteKey); err != nil {
+		return false, err
+	}
 
-	result, err := s.Algorithm.Verify(&byteKey, []byte(signingString), &byteSignature)
+	byteSignature, err := o.decodeSignature(signature)
 	if err != nil {
 		return false, err
 	}
 
-	return result, nil
+	return alg.Verify(&byteKey, data, &byteSignature)
+}
+
+// decodeKey decodes the base64 encoded key
+func decodeKey(keyB64 string) ([]byte, error) {
+	byteKey, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrorInvalidKeyEncoding, err)
+	}
+	return byteKey, nil
 }
 
 // HeaderList contains headers
@@ -231,37 +551,55 @@ type HeaderValues map[string]string
 
 // ParseString constructs a headerlist from the 'headers' string
 func (s *SignatureParameters) ParseString(list string) {
-	if len(list) == 0 {
-		return
-	}
-	list = strings.TrimSpace(list)
-	headers := strings.Split(strings.ToLower(string(list)), " ")
-	for _, header := range headers {
-		s.HeaderList = append(s.HeaderList, header)
+	// clients may separate the headers with more than one space
+	for _, header := range strings.Fields(list) {
+		s.HeaderList = append(s.HeaderList, headerName(header))
 	}
 }
 
 func (s SignatureParameters) toHeadersString() string {
 	var lowerCaseList []string
 	for _, header := range s.HeaderList {
-		lowerCaseList = append(lowerCaseList, strings.ToLower(header))
+		lowerCaseList = append(lowerCaseList, headerName(header))
 	}
 
 	return strings.Join(lowerCaseList, " ")
 }
 
-func (s SignatureParameters) signingString() (string, error) {
-	signingList := []string{}
+func (s SignatureParameters) signingString(o options) (string, error) {
+	if s.isRFC9421() {
+		signatureBase := s.signatureBase()
+		if o.normalizeUnicode {
+			signatureBase = norm.NFC.String(signatureBase)
+		}
+		return signatureBase, nil
+	}
 
-	for _, header := range s.HeaderList {
-		headerString := fmt.Sprintf("%s: %s", header, s.Headers[header])
-		signingList = append(signingList, headerString)
+	lineEnding := "\n"
+	if o.lineEnding != "" {
+		lineEnding = o.lineEnding
+	}
+	var b strings.Builder
+	for i, header := range s.HeaderList {
+		if i > 0 {
+			b.WriteString(lineEnding)
+		}
+		b.WriteString(header)
+		b.WriteString(": ")
+		b.WriteString(s.Headers[header])
 	}
 
-	return strings.Join(signingList, "\n"), nil
+	signingString := b.String()
+	if o.normalizeUnicode {
+		signingString = norm.NFC.String(signingString)
+	}
+	return signingString, nil
 }
 
-func requestTargetLine(req *http.Request) (string, error) {
+// requestTargetLine returns the (request-target) value. It is built from r.URL
+// rather than r.RequestURI, which is only set on server requests, so client
+// requests and server requests built by net/http or httptest.NewRequest agree.
+func requestTargetLine(req *http.Request, o options) (string, error) {
 	if req.URL == nil {
 		return "", errors.New(ErrorURLNotInRequest)
 	}
@@ -269,21 +607,108 @@ func requestTargetLine(req *http.Request) (string, error) {
 		return "", errors.New(ErrorMethodNotInRequest)
 	}
 
-	path := req.URL.Path
+	// the escaped path is the path as sent, eg /foo%2Fbar rather than /foo/bar
+	path := req.URL.EscapedPath()
+	if o.decodedPath {
+		path = req.URL.Path
+	}
+	if path == "" {
+		path = "/"
+	}
 	var query, fragment string
 	if q := req.URL.RawQuery; len(q) != 0 {
 		query = "?" + q
 	}
-	if f := req.URL.Fragment; len(f) != 0 {
+	// the fragment is not sent to the server, so it is only included for
+	// signatures of clients which signed it
+	if f := req.URL.Fragment; len(f) != 0 && o.legacyFragmentInTarget {
 		fragment = "#" + f
 	}
 	method := strings.ToLower(req.Method)
 	return fmt.Sprintf("%s %s%s%s", method, path, query, fragment), nil
 }
 
+// requestHost returns the host of the request, falling back to the host
+// of the URL for client requests which leave r.Host empty
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	if req.URL != nil {
+		return req.URL.Host
+	}
+	return ""
+}
+
+// requestHasHeader reports whether the request carries the header as read by ParseRequest
+func requestHasHeader(req *http.Request, header string) bool {
+	switch header {
+	case HeaderRequestTarget:
+		return true
+	case HeaderHost:
+		return requestHost(req) != ""
+	default:
+		if isQueryParam(header) {
+			_, err := queryParamValue(req, header)
+			return err == nil
+		}
+		return len(req.Header[http.CanonicalHeaderKey(header)]) > 0
+	}
+}
+
+// hasHeader reports whether the header is covered by the signature
+func (s SignatureParameters) hasHeader(header string) bool {
+	for _, h := range s.HeaderList {
+		if h == headerName(header) {
+			return true
+		}
+	}
+	return false
+}
+
 func headerLine(req *http.Request, header string) (string, error) {
 	if value := req.Header.Get(header); value != "" {
 		return fmt.Sprintf("%s: %s", header, value), nil
 	}
 	return "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
 }
+
+func isQueryParam(header string) bool {
+	return strings.HasPrefix(strings.ToLower(header), HeaderQueryParam)
+}
+
+// headerName returns the header as it appears in the header list: lower case,
+// except for the case sensitive name of a query parameter which is unquoted
+func headerName(header string) string {
+	if isQueryParam(header) {
+		return HeaderQueryParam + strings.Trim(header[len(HeaderQueryParam):], `"`)
+	}
+	return strings.ToLower(header)
+}
+
+// queryParamValue returns the URL encoded values of the query parameter named
+// by the (query-param) pseudo header, joined like repeated headers
+func queryParamValue(r *http.Request, header string) (string, error) {
+	if r.URL == nil {
+		return "", errors.New(ErrorURLNotInRequest)
+	}
+	name := header[len(HeaderQueryParam):]
+	values, ok := r.URL.Query()[name]
+	if !ok {
+		return "", fmt.Errorf("%s '%s'", ErrorMissingRequiredHeader, header)
+	}
+	var encoded []string
+	for _, value := range values {
+		encoded = append(encoded, url.QueryEscape(value))
+	}
+	return strings.Join(encoded, ", "), nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
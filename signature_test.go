@@ -164,7 +164,7 @@ func TestRequestParserMissingDateHeader(t *testing.T) {
 	err = s.FromRequest(r)
 	assert.Nil(t, err)
 	sigParam := SignatureParameters{KeyID: "Test", Algorithm: algorithmHmacSha256,
-		Headers:   HeaderValues{"(request-target)": "post /foo?param=value&pet=dog#bar", "host": "example.com"},
+		Headers:   HeaderValues{"(request-target)": "post /foo?param=value&pet=dog", "host": "example.com"},
 		Signature: "fffff", HeaderList: []string{"(request-target)", "host"}}
 	assert.Equal(t, sigParam, s)
 }
@@ -187,7 +187,7 @@ func TestRequestParserTestURLVersions(t *testing.T) {
 	err = s.FromRequest(r)
 	assert.Nil(t, err)
 	sigParam := SignatureParameters{KeyID: "Test", Algorithm: algorithmHmacSha256,
-		Headers:   HeaderValues{"(request-target)": "post /foo?param=value&pet=dog#bar", "host": "example.com"},
+		Headers:   HeaderValues{"(request-target)": "post /foo?param=value&pet=dog", "host": "example.com"},
 		Signature: "fffff", HeaderList: []string{"(request-target)", "host"}}
 	assert.Equal(t, sigParam, s)
 
@@ -205,7 +205,7 @@ func TestRequestParserTestURLVersions(t *testing.T) {
 	err = s.FromRequest(r)
 	assert.Nil(t, err)
 	sigParam = SignatureParameters{KeyID: "Test", Algorithm: algorithmHmacSha256,
-		Headers:   HeaderValues{"(request-target)": "post /foo?param=value#bar", "host": "example.com"},
+		Headers:   HeaderValues{"(request-target)": "post /foo?param=value", "host": "example.com"},
 		Signature: "fffff", HeaderList: []string{"(request-target)", "host"}}
 	assert.Equal(t, sigParam, s)
 
@@ -290,7 +290,7 @@ func TestParseRequestWithNoHostShouldFail(t *testing.T) {
 		Method: http.MethodPost,
 	}
 
-	_, err := requestTargetLine(r)
+	_, err := requestTargetLine(r, options{})
 	assert.EqualError(t, err, ErrorURLNotInRequest)
 	httpErr, _ := ErrorToHTTPCode(err.Error())
 	assert.Equal(t, http.StatusBadRequest, httpErr)
@@ -308,8 +308,260 @@ func TestParseRequestWithNoMethodShouldFail(t *testing.T) {
 		URL:  u,
 	}
 
-	_, err = requestTargetLine(r)
+	_, err = requestTargetLine(r, options{})
 	assert.EqualError(t, err, ErrorMethodNotInRequest)
 	httpErr, _ := ErrorToHTTPCode(err.Error())
 	assert.Equal(t, http.StatusBadRequest, httpErr)
 }
+
+func TestParseRequestHostFromURL(t *testing.T) {
+	const authHeader string = `keyId="Test",algorithm="hmac-sha256",signature="fffff",headers="(request-target) host"`
+	u, err := url.Parse("https://www.example.com/foo")
+	assert.Nil(t, err)
+	r := &http.Request{
+		Header: http.Header{
+			"Authorization": []string{authHeader},
+		},
+		Method: http.MethodPost,
+		URL:    u,
+	}
+
+	var s SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, HeaderValues{"(request-target)": "post /foo", "host": "www.example.com"}, s.Headers)
+
+	r.URL = &url.URL{Path: "/foo"}
+	err = s.FromRequest(r)
+	assert.EqualError(t, err, ErrorMissingRequiredHeader+" 'host'")
+}
+
+func TestRequestTargetLineRootPath(t *testing.T) {
+	for _, rawURL := range []string{"https://www.example.com", "https://www.example.com/"} {
+		u, err := url.Parse(rawURL)
+		assert.Nil(t, err)
+		r := &http.Request{
+			Method: http.MethodGet,
+			URL:    u,
+		}
+
+		tl, err := requestTargetLine(r, options{})
+		assert.Nil(t, err)
+		assert.Equal(t, "get /", tl)
+	}
+
+	u, err := url.Parse("https://www.example.com?param=value")
+	assert.Nil(t, err)
+	tl, err := requestTargetLine(&http.Request{Method: http.MethodGet, URL: u}, options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "get /?param=value", tl)
+}
+
+func TestValidateAuthorizationHeader(t *testing.T) {
+	valid := []string{
+		`Signature keyId="Test",algorithm="hmac-sha256",signature="fffff"`,
+		`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff"`,
+		`keyId="Test", algorithm="ed25519",
+			signature="fffff"`,
+		DefaultTestAuthHeader,
+	}
+	for _, header := range valid {
+		assert.Nil(t, ValidateAuthorizationHeader(header), header)
+	}
+}
+
+func TestValidateAuthorizationHeaderMalformed(t *testing.T) {
+	malformed := []string{
+		``,
+		`Signature`,
+		`keyId="Test",algorithm="hmac-sha256",signature="fffff`,
+		`keyId="Test",algorithm=hmac-sha256,signature="fffff"`,
+		`keyId="Test" algorithm="hmac-sha256" signature="fffff"`,
+		`keyId="Test",algorithm="hmac-sha256",signature="fffff",`,
+		`Basic dXNlcjpwYXNz`,
+	}
+	for _, header := range malformed {
+		err := ValidateAuthorizationHeader(header)
+		assert.EqualError(t, err, ErrorMalformedSignatureHeader, header)
+	}
+
+	err := ValidateAuthorizationHeader(`keyId="Test",algorithm="hmac-sha256"`)
+	assert.EqualError(t, err, ErrorMissingSignatureParameterSignature)
+	err = ValidateAuthorizationHeader(`algorithm="hmac-sha256",signature="fffff"`)
+	assert.EqualError(t, err, ErrorMissingSignatureParameterKeyId)
+	err = ValidateAuthorizationHeader(`keyId="Test",algorithm="rot13",signature="fffff"`)
+	assert.Equal(t, errorUnknownAlgorithm, err)
+}
+
+func TestAuthorizationHeadersEquivalent(t *testing.T) {
+	header := `Signature keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff"`
+	equivalent := []string{
+		header,
+		`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff"`,
+		`signature="fffff", headers="(request-target)  host date" , keyId="Test",algorithm="hmac-sha256"`,
+		`  signature   keyId="Test",	algorithm="hmac-sha256", signature="fffff",headers="(request-target) host date"`,
+	}
+	for _, other := range equivalent {
+		assert.True(t, AuthorizationHeadersEquivalent(header, other), other)
+		assert.True(t, AuthorizationHeadersEquivalent(other, header), other)
+	}
+
+	different := []string{
+		`keyId="Other",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff"`,
+		`keyId="Test",algorithm="hmac-sha1",headers="(request-target) host date",signature="fffff"`,
+		`keyId="Test",algorithm="hmac-sha256",headers="host (request-target) date",signature="fffff"`,
+		`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="eeeee"`,
+		`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff",created=1`,
+		`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date"`,
+	}
+	for _, other := range different {
+		assert.False(t, AuthorizationHeadersEquivalent(header, other), other)
+	}
+}
+
+func TestHeaderListOrderIsPreserved(t *testing.T) {
+	headers := []string{"x-zeta", "date", "(request-target)", "x-alpha", "host"}
+	u, err := url.Parse("https://www.example.com/foo")
+	assert.Nil(t, err)
+	r := &http.Request{
+		Header: http.Header{
+			"Date":    []string{testDate},
+			"X-Zeta":  []string{"z"},
+			"X-Alpha": []string{"a"},
+		},
+		Method: http.MethodGet,
+		URL:    u,
+	}
+
+	var s SignatureParameters
+	err = s.FromConfig("Test", "hmac-sha256", headers)
+	assert.Nil(t, err)
+	err = s.ParseRequest(r)
+	assert.Nil(t, err)
+
+	signingString, err := s.signingString(options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "x-zeta: z\n"+
+		"date: "+testDate+"\n"+
+		"(request-target): get /foo\n"+
+		"x-alpha: a\n"+
+		"host: www.example.com", signingString)
+
+	signature := s.hTTPSignatureString("sig")
+	assert.Contains(t, signature, `headers="x-zeta date (request-target) x-alpha host"`)
+
+	var parsed SignatureParameters
+	err = parsed.parseSignatureString(signature, options{})
+	assert.Nil(t, err)
+	assert.Equal(t, headers, parsed.HeaderList)
+}
+
+func TestConfigParserDuplicateHeaderShouldFail(t *testing.T) {
+	var s SignatureParameters
+	err := s.FromConfig("Test", "hmac-sha256", []string{"date", "date", "host"})
+	assert.EqualError(t, err, ErrorDuplicateHeader+" 'date'")
+	httpErr, _ := ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+
+	s = SignatureParameters{}
+	err = s.FromConfig("Test", "hmac-sha256", []string{"Date", "host", "date"})
+	assert.EqualError(t, err, ErrorDuplicateHeader+" 'date'")
+}
+
+func TestValidateAuthorizationHeaderWithTimestamps(t *testing.T) {
+	err := ValidateAuthorizationHeader(`keyId="Test",algorithm="hs2019",created=1402170695,expires=1402170995,` +
+		`headers="(created) (expires)",signature="abc"`)
+	assert.Nil(t, err)
+}
+
+func TestRequestParserStrictParameters(t *testing.T) {
+	const authHeader string = `Signature keyId="Test",algorithm="hmac-sha256",
+		garbage="bob",signature="fffff"`
+	r := &http.Request{
+		Header: http.Header{
+			"Date":          []string{testDate},
+			"Authorization": []string{authHeader},
+		},
+	}
+
+	var s SignatureParameters
+	err := s.fromRequest(r, newOptions([]Option{WithStrictParameters()}))
+	assert.EqualError(t, err, ErrorUnknownSignatureParameter+" 'garbage'")
+	httpErr, _ := ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	r.Header.Set("Authorization", `Signature keyId="Test",algorithm="hmac-sha256",version=2,signature="fffff"`)
+	err = s.fromRequest(r, newOptions([]Option{WithStrictParameters()}))
+	assert.EqualError(t, err, ErrorUnknownSignatureParameter+" 'version'")
+
+	r.Header.Set("Authorization", `Signature keyId="Test",algorithm="hmac-sha256",created=1402170695,`+
+		`expires=1402170995,headers="date",profile="p",signature="fffff"`)
+	err = s.fromRequest(r, newOptions([]Option{WithStrictParameters()}))
+	assert.Nil(t, err)
+}
+
+func TestRequestTargetLineFragment(t *testing.T) {
+	u, err := url.Parse("https://www.example.com/foo?param=value#bar")
+	assert.Nil(t, err)
+	r := &http.Request{Method: http.MethodGet, URL: u}
+
+	tl, err := requestTargetLine(r, options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "get /foo?param=value", tl)
+
+	tl, err = requestTargetLine(r, newOptions([]Option{WithLegacyFragmentInTarget()}))
+	assert.Nil(t, err)
+	assert.Equal(t, "get /foo?param=value#bar", tl)
+}
+
+func TestCreatedHeaderWithoutParameter(t *testing.T) {
+	const header = `keyId="Test",algorithm="hmac-sha256",headers="(created) date",signature="abc"`
+	err := ValidateAuthorizationHeader(header)
+	assert.EqualError(t, err, ErrorMissingCreatedParameter)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date":          []string{testDate},
+			"Authorization": []string{"Signature " + header},
+		},
+	}
+	var s SignatureParameters
+	err = s.FromRequest(r)
+	assert.EqualError(t, err, ErrorMissingCreatedParameter)
+	httpErr, _ := ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	// without the pseudo header the parameter is optional
+	err = ValidateAuthorizationHeader(`keyId="Test",algorithm="hmac-sha256",headers="date",signature="abc"`)
+	assert.Nil(t, err)
+}
+
+func FuzzParseSignatureString(f *testing.F) {
+	f.Add(`keyId="Test",algorithm="hmac-sha256",headers="(request-target) host date",signature="fffff"`)
+	f.Add(`Signature keyId="Test",algorithm="hs2019",created=1402170695,expires="1402170699",signature="fffff"`)
+	f.Add(`keyId="Test",keyId="Other",algorithm="rsa-sha256",headers="",signature="a,b=c"`)
+	f.Add(`keyId="Test" , algorithm="ed25519",headers="(created)",signature="",signature="fffff"`)
+	f.Fuzz(func(t *testing.T, in string) {
+		var s, again SignatureParameters
+		err := s.parseSignatureString(trimSignatureScheme(in), options{})
+		againErr := again.parseSignatureString(trimSignatureScheme(in), options{})
+		assert.Equal(t, err, againErr)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, s, again)
+
+		// the serialized parameters parse to the same parameters
+		serialized := s.hTTPSignatureString(s.Signature)
+		assert.True(t, AuthorizationHeadersEquivalent(in, serialized), serialized)
+
+		r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		assert.Nil(t, err)
+		r.Header.Set("Signature", in)
+		var fromRequest SignatureParameters
+		if fromRequest.FromRequest(r) == nil {
+			assert.Equal(t, s.KeyID, fromRequest.KeyID)
+			assert.Equal(t, s.Signature, fromRequest.Signature)
+		}
+	})
+}
@@ -1,37 +1,163 @@
 package httpsignatures
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type signer struct {
-	algorithm string
-	headers   []string
+	// template holds the algorithm and header list shared by all signatures,
+	// configErr the error configuring them
+	template  SignatureParameters
+	configErr error
+	options   options
+	// keyB64 is used when signing without a key, see NewSignerFromFile
+	keyB64 string
+	// algorithms is the preference list to select the algorithm from by the
+	// key, see NewMultiSigner
+	algorithms []*Algorithm
 }
 
-// NewSigner adds an algorithm to the signer algorithms
+// NewSigner adds an algorithm to the signer algorithms. The signer is not
+// modified by signing, so it is safe for concurrent use by multiple goroutines.
 func NewSigner(algorithm string, headers ...string) *signer {
-	return &signer{
-		algorithm: algorithm,
-		headers:   headers,
+	return NewSignerWithOptions(algorithm, headers)
+}
+
+// NewSignerWithOptions creates a signer like NewSigner, with the optional
+// behaviour configured by opts
+func NewSignerWithOptions(algorithm string, headers []string, opts ...Option) *signer {
+	s := &signer{options: newOptions(opts)}
+	s.configure(algorithm, headers)
+	if s.configErr == nil && s.options.rfc9421 {
+		s.configErr = checkRFC9421Algorithm(s.template.Algorithm)
+	}
+	return s
+}
+
+// configure sets the algorithm and header list of the template. The profile
+// of WithProfile is always signed, so it can not be changed in transit. RFC
+// 9421 signatures have no profile parameter, so it can not be combined with
+// WithRFC9421.
+func (s *signer) configure(algorithm string, headers []string) {
+	s.template = SignatureParameters{}
+	s.configErr = s.template.configure(algorithm, headers)
+	if s.configErr != nil || s.options.profile == "" {
+		return
+	}
+	if s.options.rfc9421 {
+		s.configErr = errors.New(ErrorProfileNotSupportedByRFC9421)
+	} else if !s.template.hasHeader(HeaderProfile) {
+		s.template.HeaderList = append(s.template.HeaderList, HeaderProfile)
+	}
+}
+
+// NewMultiSigner creates a signer which signs with the first of the algorithms
+// matching the key it signs with, so keys of different types can be used
+// with the same signer. HMAC algorithms match any key but a RSA private key,
+// so they should come last. HMAC and ed25519 algorithms can not be combined,
+// as an ed25519 key is also a valid HMAC secret, so the signer fails to sign.
+func NewMultiSigner(algorithms []string, headers []string, opts ...Option) *signer {
+	var first string
+	if len(algorithms) > 0 {
+		first = algorithms[0]
+	}
+	s := NewSignerWithOptions(first, headers, opts...)
+	var hmac, ed25519 bool
+	for _, algorithm := range algorithms {
+		alg, err := algorithmFromString(algorithm)
+		if err != nil {
+			s.configErr = err
+			break
+		}
+		hmac = hmac || alg.Kind == AlgorithmKindHMAC
+		ed25519 = ed25519 || alg.Kind == AlgorithmKindEd25519
+		s.algorithms = append(s.algorithms, alg)
+	}
+	if s.configErr == nil && hmac && ed25519 {
+		s.configErr = errors.New(ErrorHMACWithEd25519)
 	}
+	return s
 }
 
-// SignRequest adds a http signature to the Signature: HTTP Header
+// selectAlgorithm returns the first algorithm of the signer the key matches.
+// A key which can not be decoded for an algorithm does not match it, eg a raw
+// HMAC secret with WithRawHMACKey is not valid base64 for RSA.
+func (s signer) selectAlgorithm(keyB64 string) (*Algorithm, error) {
+	var names []string
+	var decodeErr error
+	decoded := false
+	for _, alg := range s.algorithms {
+		names = append(names, alg.Name)
+		key, err := s.options.decodeKey(alg, keyB64)
+		if err != nil {
+			decodeErr = err
+			continue
+		}
+		decoded = true
+		if checkSigningKey(alg, key) == nil {
+			return alg, nil
+		}
+	}
+	if !decoded {
+		return nil, decodeErr
+	}
+	return nil, fmt.Errorf("%s: '%s'", ErrorKeyAlgorithmMismatch, strings.Join(names, ", "))
+}
+
+// SignRequest adds a http signature to the Signature: HTTP Header, or the
+// header set with WithSignatureHeaderName
 func (s signer) SignRequest(r *http.Request, keyID string, keyB64 string) error {
+	s.setDate(r)
+	if s.options.rfc9421 {
+		return s.signRFC9421(r, keyID, keyB64)
+	}
 	signature, err := s.createHTTPSignatureString(r, keyID, keyB64)
 	if err != nil {
 		return err
 	}
 
-	r.Header.Add("Signature", signature)
+	header := "Signature"
+	if s.options.signatureHeader != "" {
+		header = s.options.signatureHeader
+	}
+	r.Header.Add(header, signature)
 	return nil
 }
 
-// AuthRequest adds a http signature to the Authorization: HTTP Header
+// SignRequestWith adds a http signature like SignRequest, signing headers
+// instead of the header list of the signer for this request only
+func (s signer) SignRequestWith(r *http.Request, keyID string, keyB64 string, headers []string) error {
+	if s.configErr == nil {
+		s.configure(s.template.Algorithm.Name, headers)
+	}
+	return s.SignRequest(r, keyID, keyB64)
+}
+
+// SignedClone returns a signed copy of the request like SignRequest, leaving
+// the request itself unchanged, eg to sign each retry of a request anew. The
+// copy shares the body of the request.
+func (s signer) SignedClone(r *http.Request, keyID string, keyB64 string) (*http.Request, error) {
+	clone := r.Clone(r.Context())
+	if err := s.SignRequest(clone, keyID, keyB64); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// AuthRequest adds a http signature to the Authorization: HTTP Header. RFC 9421
+// signatures are added to the Signature-Input and Signature: HTTP Headers, as
+// they have no Authorization scheme.
 func (s signer) AuthRequest(r *http.Request, keyID string, keyB64 string) error {
+	s.setDate(r)
+	if s.options.rfc9421 {
+		return s.signRFC9421(r, keyID, keyB64)
+	}
 	signature, err := s.createHTTPSignatureString(r, keyID, keyB64)
 	if err != nil {
 		return err
@@ -41,76 +167,426 @@ func (s signer) AuthRequest(r *http.Request, keyID string, keyB64 string) error
 	return nil
 }
 
+// Signature returns the encoded signature of the request without adding it to
+// the request, for callers which transport the signature differently. With
+// WithCreated and a signed date header, the signature covers a Date header of
+// the WithCreated time, which the caller must send.
+func (s signer) Signature(r *http.Request, keyID string, keyB64 string) (string, error) {
+	if s.setsDate() {
+		r = r.Clone(r.Context())
+		s.setDate(r)
+	}
+	_, signature, err := s.sign(r, keyID, keyB64)
+	return signature, err
+}
+
+// setsDate reports whether signing sets the Date header, see WithCreated
+func (s signer) setsDate() bool {
+	return !s.options.created.IsZero() && s.template.hasHeader(HeaderDate)
+}
+
+// setDate sets the Date header to the WithCreated time when the date is signed
+func (s signer) setDate(r *http.Request) {
+	if s.setsDate() {
+		r.Header.Set("Date", s.options.created.UTC().Format(http.TimeFormat))
+	}
+}
+
+// signRFC9421 adds a RFC 9421 signature to the Signature-Input and Signature: HTTP Headers
+func (s signer) signRFC9421(r *http.Request, keyID string, keyB64 string) error {
+	sig, signature, err := s.sign(r, keyID, keyB64)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature-Input", rfc9421Label+"="+sig.signatureParams)
+	r.Header.Set("Signature", rfc9421Label+"=:"+signature+":")
+	return nil
+}
+
 func (s signer) createHTTPSignatureString(r *http.Request, keyID string, keyB64 string) (string, error) {
-	sig := SignatureParameters{}
-	if err := sig.FromConfig(keyID, s.algorithm, s.headers); err != nil {
+	sig, signature, err := s.sign(r, keyID, keyB64)
+	if err != nil {
 		return "", err
 	}
+	return sig.hTTPSignatureString(signature), nil
+}
 
-	if err := sig.ParseRequest(r); err != nil {
-		return "", err
+// sign returns the signature parameters and the encoded signature of the request
+func (s signer) sign(r *http.Request, keyID string, keyB64 string) (SignatureParameters, string, error) {
+	if keyID == "" && keyB64 == "" && s.options.keySource != nil {
+		var err error
+		if keyID, keyB64, err = s.options.keySource(); err != nil {
+			return SignatureParameters{}, "", err
+		}
+	}
+	if keyB64 == "" {
+		keyB64 = s.keyB64
+	}
+
+	if len(keyID) == 0 {
+		return SignatureParameters{}, "", errors.New(ErrorNoKeyIDConfigured)
+	}
+	if s.configErr != nil {
+		return SignatureParameters{}, "", s.configErr
+	}
+	sig := s.template
+	sig.KeyID = keyID
+	if len(s.algorithms) > 1 {
+		alg, err := s.selectAlgorithm(keyB64)
+		if err != nil {
+			return SignatureParameters{}, "", err
+		}
+		sig.Algorithm = alg
+	}
+	if len(s.options.optionalHeaders) > 0 {
+		sig.HeaderList = s.options.presentHeaders(r, sig.HeaderList)
+	}
+	sig.Profile = s.options.profile
+	now := s.options.now()
+	if !s.options.created.IsZero() {
+		now = s.options.created
+	}
+	if sig.hasHeader(HeaderCreated) || s.options.expiry > 0 || s.options.rfc9421 {
+		if sig.hasHeader(HeaderCreated) || s.options.rfc9421 {
+			sig.Created = now.Unix()
+		}
+		if s.options.expiry > 0 {
+			sig.Expires = now.Add(s.options.expiry).Unix()
+		}
+	}
+
+	if s.options.rfc9421 {
+		sig.signatureParams = sig.rfc9421SignatureParams()
 	}
 
-	signature, err := sig.calculateSignature(keyB64)
+	if err := sig.parseRequest(r, s.options); err != nil {
+		return SignatureParameters{}, "", err
+	}
+
+	signature, err := sig.calculateSignature(keyB64, s.options)
 	if err != nil {
-		return "", err
+		return SignatureParameters{}, "", err
 	}
+	return sig, signature, nil
+}
 
-	return sig.hTTPSignatureString(signature), nil
+// defaultClockSkew is the clock skew of WithDefaultClockSkew, as time.Duration
+var defaultClockSkew = int64(5 * time.Minute)
+
+// SetDefaultClockSkew sets the allowed clock skew of verifications with
+// WithDefaultClockSkew, like WithClockSkew, so applications can set it in one
+// place. A negative d disables the clock skew check. The default is 5 minutes.
+func SetDefaultClockSkew(d time.Duration) {
+	atomic.StoreInt64(&defaultClockSkew, int64(d))
 }
 
 // VerifyRequest verifies the signature added to the request and returns true if it is OK
 func VerifyRequest(r *http.Request, keyLookUp func(keyID string) (string, error), allowedClockSkew int,
 	allowedAlgorithms []string, requiredHeaders ...string) (bool, error) {
+	return VerifyRequestWithOptions(r, keyLookUp, allowedClockSkew, allowedAlgorithms, requiredHeaders)
+}
+
+// VerifyRequestWithOptions verifies the signature added to the request like VerifyRequest,
+// with the optional behaviour configured by opts
+func VerifyRequestWithOptions(r *http.Request, keyLookUp func(keyID string) (string, error), allowedClockSkew int,
+	allowedAlgorithms []string, requiredHeaders []string, opts ...Option) (bool, error) {
+	keyLookUpContext := func(ctx context.Context, keyID string) (string, error) {
+		return keyLookUp(keyID)
+	}
+	return VerifyRequestContext(context.Background(), r, keyLookUpContext, allowedClockSkew, allowedAlgorithms,
+		requiredHeaders, opts...)
+}
+
+// VerifyRequestContext verifies the signature added to the request like VerifyRequestWithOptions,
+// passing ctx to keyLookUp so a slow key lookup can be cancelled
+func VerifyRequestContext(ctx context.Context, r *http.Request,
+	keyLookUp func(ctx context.Context, keyID string) (string, error), allowedClockSkew int,
+	allowedAlgorithms []string, requiredHeaders []string, opts ...Option) (bool, error) {
+	_, valid, err := verifyRequest(ctx, r, keyLookUp, allowedClockSkew, allowedAlgorithms, requiredHeaders,
+		newOptions(opts))
+	return valid, err
+}
+
+// VerifyResult describes the signature which was verified
+type VerifyResult struct {
+	KeyID string
+	// Algorithm is the algorithm used, for hs2019 the algorithm derived from the key
+	Algorithm     string
+	SignedHeaders []string
+}
+
+// VerifyRequestInfo verifies the signature added to the request like VerifyRequestWithOptions,
+// and on success also returns the keyId, algorithm and headers of the verified signature
+func VerifyRequestInfo(r *http.Request, keyLookUp func(keyID string) (string, error), allowedClockSkew int,
+	allowedAlgorithms []string, requiredHeaders []string, opts ...Option) (bool, VerifyResult, error) {
+	keyLookUpContext := func(ctx context.Context, keyID string) (string, error) {
+		return keyLookUp(keyID)
+	}
+	sig, valid, err := verifyRequest(context.Background(), r, keyLookUpContext, allowedClockSkew, allowedAlgorithms,
+		requiredHeaders, newOptions(opts))
+	if !valid || err != nil {
+		return false, VerifyResult{}, err
+	}
+	return true, VerifyResult{
+		KeyID:         sig.KeyID,
+		Algorithm:     sig.Algorithm.Name,
+		SignedHeaders: sig.HeaderList,
+	}, nil
+}
+
+func verifyRequest(ctx context.Context, r *http.Request,
+	keyLookUp func(ctx context.Context, keyID string) (string, error), allowedClockSkew int,
+	allowedAlgorithms []string, requiredHeaders []string, o options) (SignatureParameters, bool, error) {
 
 	sig := SignatureParameters{}
 
-	if err := sig.FromRequest(r); err != nil {
-		return false, err
+	if err := sig.fromRequest(r, o); err != nil {
+		return sig, false, err
+	}
+	if host := o.trustedHost(r); host != "" && sig.hasHeader(HeaderHost) {
+		sig.Headers[HeaderHost] = host
 	}
 
-	isAlgorithmAllowed := false
-	for _, algorithm := range allowedAlgorithms {
-		if sig.Algorithm.Name == algorithm {
-			isAlgorithmAllowed = true
-			break
+	if len(allowedAlgorithms) == 0 && o.keyAlgorithms != nil {
+		algorithms, err := o.keyAlgorithms(sig.KeyID)
+		if err != nil {
+			o.notify(VerifyEventAlgorithmChecked, sig, err)
+			return sig, false, err
 		}
+		allowedAlgorithms = algorithms
 	}
-	if !isAlgorithmAllowed {
-		return false, errors.New(ErrorAlgorithmNotAllowed)
+
+	// the algorithm of hs2019 signatures is derived from the key, so it is
+	// checked after the key lookup
+	derived := sig.Algorithm.Name == AlgorithmHs2019
+	if !derived {
+		err := checkAlgorithmAllowed(sig, allowedAlgorithms)
+		if err == nil {
+			err = checkKeyAlgorithm(sig, o.keyAlgorithm)
+		}
+		o.notify(VerifyEventAlgorithmChecked, sig, err)
+		if err != nil {
+			return sig, false, err
+		}
 	}
 
 	for _, header := range requiredHeaders {
-		if sig.Headers[header] == "" {
-			return false, errors.New(ErrorRequiredHeaderNotInHeaderList + ": '" + header + "'")
+		if err := checkRequiredHeader(sig, header); err != nil {
+			return sig, false, err
 		}
 	}
 
-	if allowedClockSkew > -1 {
-		if allowedClockSkew == 0 {
-			return false, errors.New(ErrorYouProbablyMisconfiguredAllowedClockSkew)
+	if o.requiredProfile != "" {
+		if sig.Profile != o.requiredProfile {
+			return sig, false, errors.New(ErrorProfileMismatch)
 		}
-		// check if difference between date and date.Now exceeds allowedClockSkew
-		var date string
-		// if 'X-Date' header exists, prefer this header above 'Date'
-		if d := sig.Headers["x-date"]; len(d) != 0 {
-			date = d
-		} else if d := sig.Headers["date"]; len(d) != 0 {
-			date = d
-		} else {
-			return false, errors.New(ErrorDateHeaderIsMissingForClockSkewComparison)
+		// an unsigned profile can be changed in transit
+		if err := checkRequiredHeader(sig, HeaderProfile); err != nil {
+			return sig, false, err
 		}
-		if hdrDate, err := time.Parse(time.RFC1123, date); err == nil {
-			if (int)(time.Since(hdrDate).Seconds()) > (allowedClockSkew) {
-				return false, errors.New(ErrorAllowedClockskewExceeded)
-			}
-		} else {
-			return false, err
+	}
+
+	for _, header := range o.signedIfPresent {
+		if requestHasHeader(r, header) && !sig.hasHeader(header) {
+			return sig, false, errors.New(ErrorSensitiveHeaderNotSigned + ": '" + header + "'")
+		}
+	}
+
+	for _, header := range o.forbiddenHeaders {
+		if sig.hasHeader(header) {
+			return sig, false, errors.New(ErrorForbiddenHeaderSigned + ": '" + headerName(header) + "'")
+		}
+	}
+
+	for header, value := range o.expectedHeaders {
+		if err := checkExpectedHeader(sig, header, value); err != nil {
+			return sig, false, err
+		}
+	}
+
+	if checked, err := checkClockSkewOptions(sig, allowedClockSkew, o); checked {
+		o.notify(VerifyEventClockSkewChecked, sig, err)
+		if err != nil {
+			return sig, false, err
+		}
+	}
+
+	if o.dateCreatedSkew > 0 {
+		if err := checkDateCreatedConsistency(sig, o.dateCreatedSkew); err != nil {
+			return sig, false, err
+		}
+	}
+
+	if sig.Created != 0 && sig.Expires != 0 && sig.Expires <= sig.Created {
+		return sig, false, errors.New(ErrorInvalidExpiry)
+	}
+	if sig.Expires != 0 && o.now().Unix() > sig.Expires {
+		return sig, false, errors.New(ErrorSignatureExpired)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return sig, false, err
+	}
+	key, err := keyLookUp(ctx, sig.KeyID)
+	o.notify(VerifyEventKeyLookedUp, sig, err)
+	if err != nil {
+		return sig, false, err
+	}
+
+	if derived {
+		err := sig.resolveAlgorithm(key)
+		if err == nil {
+			err = checkAlgorithmAllowed(sig, allowedAlgorithms)
+		}
+		if err == nil {
+			err = checkKeyAlgorithm(sig, o.keyAlgorithm)
+		}
+		o.notify(VerifyEventAlgorithmChecked, sig, err)
+		if err != nil {
+			return sig, false, err
+		}
+	}
+
+	if o.minStrength > 0 {
+		if err := checkStrength(sig, key, o); err != nil {
+			return sig, false, err
+		}
+	}
+
+	valid, err := sig.verify(key, o)
+	o.notify(VerifyEventVerified, sig, err)
+	return sig, valid, err
+}
+
+// checkExpectedHeader checks that the header is signed with the expected value
+func checkExpectedHeader(sig SignatureParameters, header string, value string) error {
+	if err := checkRequiredHeader(sig, header); err != nil {
+		return err
+	}
+	signed := sig.Headers[header]
+	if signed != value && !(header == HeaderHost && strings.EqualFold(signed, value)) {
+		return errors.New(ErrorUnexpectedHeaderValue + ": '" + header + "'")
+	}
+	return nil
+}
+
+func checkAlgorithmAllowed(sig SignatureParameters, allowedAlgorithms []string) error {
+	if len(allowedAlgorithms) == 0 {
+		return errors.New(ErrorNoAllowedAlgorithmsConfigured)
+	}
+	for _, algorithm := range allowedAlgorithms {
+		if strings.EqualFold(sig.Algorithm.Name, algorithm) {
+			return nil
+		}
+	}
+	return errors.New(ErrorAlgorithmNotAllowed)
+}
+
+// checkKeyAlgorithm checks that the signature uses the algorithm bound to its key
+func checkKeyAlgorithm(sig SignatureParameters, keyAlgorithm func(keyID string) (string, error)) error {
+	if keyAlgorithm == nil {
+		return nil
+	}
+	algorithm, err := keyAlgorithm(sig.KeyID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sig.Algorithm.Name, algorithm) {
+		return fmt.Errorf("%s: '%s'", ErrorAlgorithmNotAllowedForKey, sig.KeyID)
+	}
+	return nil
+}
+
+func checkRequiredHeader(sig SignatureParameters, header string) error {
+	if !sig.hasHeader(header) {
+		return errors.New(ErrorRequiredHeaderNotInHeaderList + ": '" + header + "'")
+	}
+	return nil
+}
+
+// checkClockSkewOptions checks the clock skew set by the options, or else
+// allowedClockSkew, and reports whether the clock skew was checked
+func checkClockSkewOptions(sig SignatureParameters, allowedClockSkew int, o options) (bool, error) {
+	if o.defaultClockSkew {
+		o.clockSkewSet = true
+		o.clockSkew = time.Duration(atomic.LoadInt64(&defaultClockSkew))
+	}
+	if o.clockSkewSet {
+		if o.clockSkew < 0 {
+			return false, nil
 		}
+		return true, checkClockSkewDuration(sig, o.clockSkew, o)
+	}
+	if allowedClockSkew <= -1 {
+		return false, nil
 	}
-	key, err := keyLookUp(sig.KeyID)
+	return true, checkClockSkew(sig, allowedClockSkew, o)
+}
+
+func checkClockSkew(sig SignatureParameters, allowedClockSkew int, o options) error {
+	if allowedClockSkew == 0 {
+		return errors.New(ErrorYouProbablyMisconfiguredAllowedClockSkew)
+	}
+	// check if difference between the signing time and now exceeds allowedClockSkew
+	signed, err := signingTime(sig, o.freshnessSource)
 	if err != nil {
-		return false, err
+		return err
+	}
+	if (int)(o.now().Sub(signed).Seconds()) > (allowedClockSkew) {
+		return errors.New(ErrorAllowedClockskewExceeded)
+	}
+	return nil
+}
+
+// checkClockSkewDuration checks that the signing time is at most skew from now,
+// at the precision of one second
+func checkClockSkewDuration(sig SignatureParameters, skew time.Duration, o options) error {
+	signed, err := signingTime(sig, o.freshnessSource)
+	if err != nil {
+		return err
+	}
+	diff := o.now().Sub(signed).Truncate(time.Second)
+	if diff > skew || diff < -skew {
+		return errors.New(ErrorAllowedClockskewExceeded)
+	}
+	return nil
+}
+
+// checkDateCreatedConsistency checks that the signed date and created
+// parameter are at most skew apart, when both are signed
+func checkDateCreatedConsistency(sig SignatureParameters, skew time.Duration) error {
+	if !sig.createdSigned() || (!sig.hasHeader(HeaderDate) && !sig.hasHeader(HeaderXDate)) {
+		return nil
+	}
+	date, err := signingTime(sig, FreshnessSourceDate)
+	if err != nil {
+		return err
+	}
+	diff := date.Sub(time.Unix(sig.Created, 0))
+	if diff > skew || diff < -skew {
+		return errors.New(ErrorDateCreatedMismatch)
+	}
+	return nil
+}
+
+// signingTime returns the time the signature was created according to source,
+// only a signed created parameter is used
+func signingTime(sig SignatureParameters, source FreshnessSource) (time.Time, error) {
+	if source != FreshnessSourceDate && sig.Created != 0 && sig.createdSigned() {
+		return time.Unix(sig.Created, 0), nil
+	}
+	if source == FreshnessSourceCreated {
+		return time.Time{}, errors.New(ErrorCreatedIsMissingForClockSkewComparison)
+	}
+
+	var date string
+	// if 'X-Date' header exists, prefer this header above 'Date'
+	if d := sig.Headers["x-date"]; len(d) != 0 {
+		date = d
+	} else if d := sig.Headers["date"]; len(d) != 0 {
+		date = d
+	} else {
+		return time.Time{}, errors.New(ErrorDateHeaderIsMissingForClockSkewComparison)
 	}
-	return sig.Verify(key)
+	return time.Parse(time.RFC1123, date)
 }
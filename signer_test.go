@@ -1,10 +1,25 @@
 package httpsignatures_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -152,6 +167,34 @@ func TestSignSha256DoubleHeaderField(t *testing.T) {
 		"date": "Thu, 05 Jan 2012 21:31:40 GMT"}, s.Headers)
 }
 
+func TestSignAndVerifyMultiValuedHeader(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"X-Custom": []string{"v1", "v2"},
+			"Date":     []string{testDate},
+		},
+	}
+
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "x-custom")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1, v2", s.Headers["x-custom"])
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the order of the values is part of the signed value
+	r.Header["X-Custom"] = []string{"v2", "v1"}
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
 func TestSignWithMissingDateHeader(t *testing.T) {
 	r := &http.Request{
 		Header: http.Header{},
@@ -178,6 +221,23 @@ func TestSignWithMissingHeader(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, httpErr)
 }
 
+func TestSignWithMissingConfiguredHeaderAddsNoSignature(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+
+	s := httpsignatures.NewSigner("hmac-sha256", "date", "content-type")
+
+	err := s.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" 'content-type'")
+	err = s.AuthRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" 'content-type'")
+	assert.Empty(t, r.Header.Get("Signature"))
+	assert.Empty(t, r.Header.Get("Authorization"))
+}
+
 // Verifying
 func keyLookUp(keyID string) (string, error) {
 	return testKey, nil
@@ -217,6 +277,24 @@ func TestValidRequestHasRequiredAlgorithm(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestVerifyWithoutAllowedAlgorithms(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	for _, allowedAlgorithms := range [][]string{nil, {}} {
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, allowedAlgorithms)
+		assert.False(t, res)
+		assert.EqualError(t, err, httpsignatures.ErrorNoAllowedAlgorithmsConfigured)
+		httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+		assert.Equal(t, http.StatusInternalServerError, httpErr)
+	}
+}
+
 func TestNotValidIfRequestHeadersChange(t *testing.T) {
 	r := &http.Request{
 		Header: http.Header{
@@ -235,22 +313,29 @@ func TestNotValidIfRequestHeadersChange(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, httpErr)
 }
 
+// clockAfterTestDate returns a clock option for d after testDate
+func clockAfterTestDate(d time.Duration) httpsignatures.Option {
+	date, _ := time.Parse(http.TimeFormat, testDate)
+	return httpsignatures.WithClock(func() time.Time { return date.Add(d) })
+}
+
 func TestNotValidIfClockSkewExceeded(t *testing.T) {
 	allowedClockSkew := 300
-	duration, err := time.ParseDuration(fmt.Sprintf("-%ds", allowedClockSkew))
-	assert.Nil(t, err)
+	clock := clockAfterTestDate(time.Duration(allowedClockSkew) * time.Second)
 	r := &http.Request{
 		Header: http.Header{
-			"Date": []string{time.Now().Add(duration).Format(time.RFC1123)},
+			"Date": []string{testDate},
 		},
 	}
-	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
 	assert.Nil(t, err)
 
-	_, err = httpsignatures.VerifyRequest(r, keyLookUp, allowedClockSkew, []string{httpsignatures.AlgorithmHmacSha256})
+	_, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, allowedClockSkew,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, clock)
 	assert.Nil(t, err)
 
-	_, err = httpsignatures.VerifyRequest(r, keyLookUp, allowedClockSkew-1, []string{httpsignatures.AlgorithmHmacSha256})
+	_, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, allowedClockSkew-1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, clock)
 	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
 	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
 	assert.Equal(t, http.StatusBadRequest, httpErr)
@@ -263,25 +348,26 @@ func TestNotValidIfClockSkewExceeded(t *testing.T) {
 
 func TestNotValidIfClockSkewExceededXDate(t *testing.T) {
 	allowedClockSkew := 300
-	duration, err := time.ParseDuration(fmt.Sprintf("-%ds", allowedClockSkew))
-	assert.Nil(t, err)
+	clock := clockAfterTestDate(time.Duration(allowedClockSkew) * time.Second)
 	r := &http.Request{
 		Header: http.Header{
-			"X-Date": []string{time.Now().Add(duration).Format(time.RFC1123)},
+			"X-Date": []string{testDate},
 		},
 	}
 	signer := httpsignatures.NewSigner("ed25519", "x-date")
-	err = signer.SignRequest(r, ed25519TestPublicKey, ed25519TestPrivateKey)
+	err := signer.SignRequest(r, ed25519TestPublicKey, ed25519TestPrivateKey)
 	assert.Nil(t, err)
 
 	keyLookUpProp := func(keyID string) (string, error) {
 		return keyID, nil
 	}
 
-	_, err = httpsignatures.VerifyRequest(r, keyLookUpProp, allowedClockSkew, []string{httpsignatures.AlgorithmEd25519})
+	_, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUpProp, allowedClockSkew,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, clock)
 	assert.Nil(t, err)
 
-	_, err = httpsignatures.VerifyRequest(r, keyLookUpProp, allowedClockSkew-1, []string{httpsignatures.AlgorithmEd25519})
+	_, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUpProp, allowedClockSkew-1,
+		[]string{httpsignatures.AlgorithmEd25519}, nil, clock)
 	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
 }
 
@@ -333,3 +419,2254 @@ func TestCompleteFunctionality(t *testing.T) {
 	_, err = httpsignatures.VerifyRequest(r, keyLookUpProp, -1, []string{httpsignatures.AlgorithmEd25519}, "(request-target)", "host", "date")
 	assert.Nil(t, err)
 }
+
+func TestVerifyHookReportsDecisionPoints(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{time.Now().UTC().Format(time.RFC1123)},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var events []httpsignatures.VerifyEvent
+	hook := func(event httpsignatures.VerifyEvent, keyID string, algorithm string, err error) {
+		assert.Equal(t, testKeyID, keyID)
+		assert.Equal(t, httpsignatures.AlgorithmHmacSha256, algorithm)
+		assert.Nil(t, err)
+		events = append(events, event)
+	}
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithVerifyHook(hook))
+	assert.True(t, res)
+	assert.Nil(t, err)
+	assert.Equal(t, []httpsignatures.VerifyEvent{
+		httpsignatures.VerifyEventAlgorithmChecked,
+		httpsignatures.VerifyEventClockSkewChecked,
+		httpsignatures.VerifyEventKeyLookedUp,
+		httpsignatures.VerifyEventVerified,
+	}, events)
+}
+
+func TestVerifyHookReportsFailure(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var events []httpsignatures.VerifyEvent
+	var lastErr error
+	hook := func(event httpsignatures.VerifyEvent, keyID string, algorithm string, err error) {
+		events = append(events, event)
+		lastErr = err
+	}
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha1},
+		nil, httpsignatures.WithVerifyHook(hook))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowed)
+	assert.Equal(t, []httpsignatures.VerifyEvent{httpsignatures.VerifyEventAlgorithmChecked}, events)
+	assert.Equal(t, err, lastErr)
+}
+
+func TestSignAndVerifyHexEncoding(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+
+	hexEncoding := httpsignatures.WithSignatureEncoding(httpsignatures.EncodingHex)
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", nil, hexEncoding)
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "420a0265339aca1bc5065d502d79853992153170b40ee8ece4e0ec615aee0cf2", s.Signature)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, hexEncoding)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the default base64 decoding does not accept the hex signature
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.NotNil(t, err)
+}
+
+func TestVerifyAllowedAlgorithmsFromKeyMetadata(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	keyAlgorithms := map[string][]string{
+		testKeyID: {httpsignatures.AlgorithmHmacSha256},
+		"Other":   {httpsignatures.AlgorithmEd25519},
+	}
+	keyAlgorithmsLookUp := func(keyID string) ([]string, error) {
+		return keyAlgorithms[keyID], nil
+	}
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, nil, nil,
+		httpsignatures.WithKeyAlgorithms(keyAlgorithmsLookUp))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	keyAlgorithms[testKeyID] = []string{httpsignatures.AlgorithmHmacSha1}
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, nil, nil,
+		httpsignatures.WithKeyAlgorithms(keyAlgorithmsLookUp))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowed)
+
+	// an explicit allowedAlgorithms list takes precedence over the key metadata
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, nil,
+		httpsignatures.WithKeyAlgorithms(keyAlgorithmsLookUp))
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyRequestContextCancelsKeyLookUp(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	slowKeyLookUp := func(ctx context.Context, keyID string) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+			return testKey, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err := httpsignatures.VerifyRequestContext(ctx, r, slowKeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil)
+	assert.False(t, res)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	keyLookUpContext := func(ctx context.Context, keyID string) (string, error) {
+		return testKey, nil
+	}
+	res, err = httpsignatures.VerifyRequestContext(context.Background(), r, keyLookUpContext, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifySensitiveHeadersSignedIfPresent(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":         []string{testDate},
+			"Content-Type": []string{"application/json"},
+		},
+		Host: "example.com",
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// without the option unsigned headers are accepted
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithSignedIfPresent(httpsignatures.DefaultSensitiveHeaders...))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSensitiveHeaderNotSigned+": 'content-type'")
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+}
+
+func TestVerifySensitiveHeadersSigned(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":         []string{testDate},
+			"Content-Type": []string{"application/json"},
+		},
+		Host: "example.com",
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "host", "content-type")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// content-length is not present in the request, so it need not be signed
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithSignedIfPresent(httpsignatures.DefaultSensitiveHeaders...))
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyForbiddenHeaderSigned(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":            []string{testDate},
+			"X-Forwarded-For": []string{"192.0.2.1"},
+		},
+		Host: "example.com",
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "host", "x-forwarded-for")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		[]string{"date", "host"}, httpsignatures.WithForbiddenHeaders("X-Forwarded-For"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorForbiddenHeaderSigned+": 'x-forwarded-for'")
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	// forbidden headers which are present but not signed are accepted
+	r.Header.Del("Signature")
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		[]string{"date"}, httpsignatures.WithForbiddenHeaders("X-Forwarded-For"))
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignAndVerifyCRLFLineEnding(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+		Host: "example.com",
+	}
+	crlf := httpsignatures.WithSigningStringLineEnding("\r\n")
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"date", "host"}, crlf)
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	signature, err := httpsignatures.SignBytes(httpsignatures.AlgorithmHmacSha256, testKey,
+		[]byte("date: "+testDate+"\r\nhost: example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, signature, s.Signature)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, crlf)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the default line ending does not match
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestSignAndVerifyRsaPssSha512(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	pubKey := base64.StdEncoding.EncodeToString(der)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmRsaPssSha512)
+	err = signer.SignRequest(r, testKeyID, privKey)
+	assert.Nil(t, err)
+
+	rsaKeyLookUp := func(keyID string) (string, error) {
+		return pubKey, nil
+	}
+	res, err := httpsignatures.VerifyRequest(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmRsaPssSha512})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyWithUnicodeNormalization(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":   []string{testDate},
+			"X-Name": []string{"caf\u00e9"},
+		},
+	}
+	normalization := httpsignatures.WithUnicodeNormalization()
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"date", "x-name"}, normalization)
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// an intermediary changes the composition of the header value
+	r.Header.Set("X-Name", "cafe\u0301")
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, normalization)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyHs2019WithRSAKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	pubKey := base64.StdEncoding.EncodeToString(der)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmHs2019)
+	err = signer.SignRequest(r, testKeyID, privKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `algorithm="hs2019"`)
+
+	rsaKeyLookUp := func(keyID string) (string, error) {
+		return pubKey, nil
+	}
+	res, err := httpsignatures.VerifyRequest(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmRsaPssSha512})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the allowed algorithms apply to the algorithm derived from the key
+	res, err = httpsignatures.VerifyRequest(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmHs2019})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowed)
+}
+
+func TestVerifyHs2019WithEd25519Key(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmHs2019)
+	err := signer.SignRequest(r, testKeyID, ed25519TestPrivateKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, httpsignatures.AlgorithmHs2019, s.Algorithm.Name)
+	assert.Equal(t, ed25519TestSignature, s.Signature)
+
+	ed25519KeyLookUp := func(keyID string) (string, error) {
+		return ed25519TestPublicKey, nil
+	}
+	res, err := httpsignatures.VerifyRequest(r, ed25519KeyLookUp, -1, []string{httpsignatures.AlgorithmEd25519})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequest(r, ed25519KeyLookUp, -1, []string{httpsignatures.AlgorithmRsaPssSha512})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowed)
+}
+
+func TestVerifyHs2019WithUnknownKeyType(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmHs2019)
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorUnknownKeyType)
+}
+
+func TestVerifyHs2019WithHMACKey(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmHs2019)
+
+	// HMAC secrets of the size of Ed25519 keys are no Ed25519 private keys
+	for _, size := range []int{32, 64} {
+		secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x2a}, size))
+		err := signer.SignRequest(r, testKeyID, secret)
+		assert.EqualError(t, err, httpsignatures.ErrorUnknownKeyType, size)
+	}
+
+	// a 32 byte HMAC secret is read as Ed25519 public key, which fails to verify
+	secret := bytes.Repeat([]byte{0x2a}, 32)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("date: " + testDate))
+	r.Header.Set("Signature", `keyId="Test",algorithm="hs2019",headers="date",signature="`+
+		base64.StdEncoding.EncodeToString(mac.Sum(nil))+`"`)
+	res, err := httpsignatures.VerifyRequest(r, func(keyID string) (string, error) {
+		return base64.StdEncoding.EncodeToString(secret), nil
+	}, -1, []string{httpsignatures.AlgorithmEd25519, httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestSignAndVerifyProfile(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", nil, httpsignatures.WithProfile("strict-2024"))
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	signature, err := httpsignatures.SignBytes(httpsignatures.AlgorithmHmacSha256, testKey,
+		[]byte("date: "+testDate+"\n(profile): strict-2024"))
+	assert.Nil(t, err)
+	assert.Equal(t, `keyId="Test",algorithm="hmac-sha256",headers="date (profile)",profile="strict-2024",signature="`+
+		signature+`"`, r.Header.Get("Signature"))
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "strict-2024", s.Profile)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRequiredProfile("strict-2024"))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRequiredProfile("strict-2025"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorProfileMismatch)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+}
+
+func TestVerifyProfileChangedInTransit(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", nil, httpsignatures.WithProfile("lenient"))
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// a party in the middle changes the signed profile
+	r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), `profile="lenient"`, `profile="strict-2024"`, 1))
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRequiredProfile("strict-2024"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	// or adds a profile to a signature which does not sign it
+	r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",headers="date",profile="strict-2024",signature="`+
+		testSha256Hash+`"`)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRequiredProfile("strict-2024"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorRequiredHeaderNotInHeaderList+": '(profile)'")
+}
+
+func TestVerifyRequiredProfileMissing(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRequiredProfile("strict-2024"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorProfileMismatch)
+}
+
+func TestSignAndVerifyURLKeyID(t *testing.T) {
+	keyID := "https://example.com/keys/1?version=2&format=raw"
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, keyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, keyID, s.KeyID)
+	assert.Equal(t, testSha256Hash, s.Signature)
+
+	urlKeyLookUp := func(id string) (string, error) {
+		assert.Equal(t, keyID, id)
+		return testKey, nil
+	}
+	res, err := httpsignatures.VerifyRequest(r, urlKeyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyWithTrustedHostHeader(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://api.example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// the reverse proxy rewrites the host and records the original one
+	r.Host = "backend.internal:8080"
+	r.Header.Set("X-Forwarded-Host", "api.example.com, edge.example.com")
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	trusted := httpsignatures.WithTrustedHostHeader("X-Forwarded-Host")
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// without the trusted header the request host is used
+	r.Header.Del("X-Forwarded-Host")
+	r.Host = "api.example.com"
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyWithExpectedHeaderValue(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://api.example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// the reverse proxy rewrites the host and records the original one
+	r.Host = "backend.internal:8080"
+	r.Header.Set("X-Forwarded-Host", "api.example.com")
+	trusted := httpsignatures.WithTrustedHostHeader("X-Forwarded-Host")
+	expected := httpsignatures.WithExpectedHeaderValue("Host", "API.example.com")
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted, expected)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// a request signed for another host of the client passes the signature check,
+	// but not the expected value
+	r, err = http.NewRequest(http.MethodGet, "https://other.example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	r.Host = "backend.internal:8080"
+	r.Header.Set("X-Forwarded-Host", "other.example.com")
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted, expected)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorUnexpectedHeaderValue+": 'host'")
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	// the header must be signed
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, trusted, httpsignatures.WithExpectedHeaderValue("X-Tenant", "a"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorRequiredHeaderNotInHeaderList+": 'x-tenant'")
+}
+
+func TestSignAndVerifyQueryParam(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo?pet=dog&name=a+b&Pet=cat", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", `(query-param);name="pet"`, "(query-param);name=Pet", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `headers="(query-param);name=pet (query-param);name=Pet date"`)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "dog", s.Headers["(query-param);name=pet"])
+	assert.Equal(t, "cat", s.Headers["(query-param);name=Pet"])
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// other query parameters are not covered
+	r.URL.RawQuery = "pet=dog&name=other&Pet=cat"
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.URL.RawQuery = "pet=bird&Pet=cat"
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestSignQueryParamAbsent(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo?name=value", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(query-param);name=pet", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" '(query-param);name=pet'")
+
+	// an empty parameter is present
+	r.URL.RawQuery = "pet="
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestRegisterAlgorithm(t *testing.T) {
+	err := httpsignatures.RegisterAlgorithm("hmac-sha512", sha512.New, httpsignatures.AlgorithmKindHMAC)
+	assert.Nil(t, err)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = httpsignatures.NewSigner("hmac-sha512").SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	mac := hmac.New(sha512.New, key)
+	mac.Write([]byte("date: " + testDate))
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "hmac-sha512", s.Algorithm.Name)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(mac.Sum(nil)), s.Signature)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{"hmac-sha512"})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestRegisterAlgorithmErrors(t *testing.T) {
+	err := httpsignatures.RegisterAlgorithm(httpsignatures.AlgorithmHmacSha256, sha512.New,
+		httpsignatures.AlgorithmKindHMAC)
+	assert.EqualError(t, err, httpsignatures.ErrorBuiltinAlgorithm+" 'hmac-sha256'")
+
+	err = httpsignatures.RegisterAlgorithm("rsa-sha512", sha512.New, httpsignatures.AlgorithmKindRSAPKCS1v15)
+	assert.EqualError(t, err, httpsignatures.ErrorUnsupportedAlgorithmKind)
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	algorithms := httpsignatures.SupportedAlgorithms()
+	for _, algorithm := range []string{
+		httpsignatures.AlgorithmHmacSha1,
+		httpsignatures.AlgorithmHmacSha256,
+		httpsignatures.AlgorithmEd25519,
+		httpsignatures.AlgorithmRsaSha256,
+		httpsignatures.AlgorithmRsaPssSha512,
+		httpsignatures.AlgorithmHs2019,
+	} {
+		assert.Contains(t, algorithms, algorithm)
+	}
+
+	err := httpsignatures.RegisterAlgorithm("HMAC-SHA512", sha512.New, httpsignatures.AlgorithmKindHMAC)
+	assert.Nil(t, err)
+	assert.Contains(t, httpsignatures.SupportedAlgorithms(), "hmac-sha512")
+}
+
+func TestVerifyWithDefaultClockSkew(t *testing.T) {
+	defer httpsignatures.SetDefaultClockSkew(5 * time.Minute)
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{time.Now().Add(-10 * time.Minute).Format(time.RFC1123)},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithDefaultClockSkew())
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+
+	httpsignatures.SetDefaultClockSkew(15 * time.Minute)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithDefaultClockSkew())
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// an explicit clock skew is not affected by the default
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+
+	// negative values below -1 still disable the check
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -2, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// a sub-second default allows no skew, instead of being a misconfiguration
+	httpsignatures.SetDefaultClockSkew(500 * time.Millisecond)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithDefaultClockSkew())
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+
+	httpsignatures.SetDefaultClockSkew(-1)
+	r.Header.Set("Date", testDate)
+	r.Header.Del("Signature")
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithDefaultClockSkew())
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyRequestInfo(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, info, err := httpsignatures.VerifyRequestInfo(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil)
+	assert.True(t, res)
+	assert.Nil(t, err)
+	assert.Equal(t, httpsignatures.VerifyResult{
+		KeyID:         testKeyID,
+		Algorithm:     httpsignatures.AlgorithmHmacSha256,
+		SignedHeaders: []string{"(request-target)", "host", "date"},
+	}, info)
+
+	r.Header.Set("Date", "Thu, 05 Jan 2012 21:31:41 GMT")
+	res, info, err = httpsignatures.VerifyRequestInfo(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+	assert.Equal(t, httpsignatures.VerifyResult{}, info)
+}
+
+func TestVerifyRequestInfoHs2019(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := httpsignatures.NewSigner(httpsignatures.AlgorithmHs2019).SignRequest(r, testKeyID, ed25519TestPrivateKey)
+	assert.Nil(t, err)
+
+	ed25519KeyLookUp := func(keyID string) (string, error) {
+		return ed25519TestPublicKey, nil
+	}
+	res, info, err := httpsignatures.VerifyRequestInfo(r, ed25519KeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmEd25519}, nil)
+	assert.True(t, res)
+	assert.Nil(t, err)
+	assert.Equal(t, httpsignatures.AlgorithmEd25519, info.Algorithm)
+}
+
+func TestVerifySignatureAndAuthorizationHeaders(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	err = DefaultSha256Signer.AuthRequest(r, "Other", testKey)
+	assert.Nil(t, err)
+
+	// the Signature header takes precedence by default
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, testKeyID, s.KeyID)
+
+	_, info, err := httpsignatures.VerifyRequestInfo(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithAuthorizationPrecedence())
+	assert.Nil(t, err)
+	assert.Equal(t, "Other", info.KeyID)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRejectConflictingSignatures())
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorConflictingSignatureHeaders)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	// identical signatures do not conflict
+	r.Header.Set("Authorization", "Signature "+r.Header.Get("Signature"))
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRejectConflictingSignatures())
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// an Authorization header with another scheme does not conflict
+	r.Header.Set("Authorization", "Bearer token")
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithRejectConflictingSignatures(), httpsignatures.WithAuthorizationPrecedence())
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignEmitsLowercaseAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = httpsignatures.NewSigner("RSA-SHA256").SignRequest(r, testKeyID, privKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `algorithm="rsa-sha256"`)
+
+	r.Header.Del("Signature")
+	err = httpsignatures.NewSigner("Hmac-Sha256").SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, `keyId="Test",algorithm="hmac-sha256",headers="date",signature="`+testSha256Hash+`"`,
+		r.Header.Get("Signature"))
+}
+
+func TestVerifyAllowedAlgorithmsCaseInsensitive(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{"HMAC-SHA256"})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignAndVerifyCreated(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "(created)", "date")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.InDelta(t, time.Now().Unix(), s.Created, 5)
+	assert.Contains(t, r.Header.Get("Signature"), fmt.Sprintf(`,created=%d,headers="(created) date"`, s.Created))
+	assert.Equal(t, fmt.Sprint(s.Created), s.Headers["(created)"])
+
+	// the Date header is stale, the created parameter is fresh
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+
+	for _, source := range []httpsignatures.FreshnessSource{httpsignatures.FreshnessSourceCreated,
+		httpsignatures.FreshnessSourceAuto} {
+		res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300,
+			[]string{httpsignatures.AlgorithmHmacSha256}, nil, httpsignatures.WithFreshnessSource(source))
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}
+}
+
+func TestVerifyFreshnessSourceWithoutCreated(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{time.Now().Format(time.RFC1123)},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithFreshnessSource(httpsignatures.FreshnessSourceCreated))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorCreatedIsMissingForClockSkewComparison)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithFreshnessSource(httpsignatures.FreshnessSourceAuto))
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignAndVerifyExpires(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(created)", "(expires)", "date"},
+		httpsignatures.WithExpiry(5*time.Minute))
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Created+300, s.Expires)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("(expires): 1\ndate: " + testDate))
+	r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",expires=1,headers="(expires) date",signature="`+
+		base64.StdEncoding.EncodeToString(mac.Sum(nil))+`"`)
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignatureExpired)
+}
+
+func TestMultiSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	rsaKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	rsaPublicKey := base64.StdEncoding.EncodeToString(der)
+
+	asymmetric := []string{httpsignatures.AlgorithmEd25519, httpsignatures.AlgorithmRsaSha256}
+	withHMAC := []string{httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHmacSha256}
+	for _, test := range []struct {
+		algorithms []string
+		key        string
+		publicKey  string
+		algorithm  string
+	}{
+		{asymmetric, ed25519TestPrivateKey, ed25519TestPublicKey, httpsignatures.AlgorithmEd25519},
+		{asymmetric, rsaKey, rsaPublicKey, httpsignatures.AlgorithmRsaSha256},
+		{withHMAC, rsaKey, rsaPublicKey, httpsignatures.AlgorithmRsaSha256},
+		{withHMAC, testKey, testKey, httpsignatures.AlgorithmHmacSha256},
+	} {
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{testDate},
+			},
+		}
+		signer := httpsignatures.NewMultiSigner(test.algorithms, []string{"date"})
+		err := signer.SignRequest(r, testKeyID, test.key)
+		assert.Nil(t, err)
+
+		var s httpsignatures.SignatureParameters
+		err = s.FromRequest(r)
+		assert.Nil(t, err)
+		assert.Equal(t, test.algorithm, s.Algorithm.Name)
+
+		res, err := httpsignatures.VerifyRequest(r, func(keyID string) (string, error) {
+			return test.publicKey, nil
+		}, -1, []string{test.algorithm})
+		assert.True(t, res, test.algorithm)
+		assert.Nil(t, err, test.algorithm)
+	}
+
+	// without a matching algorithm the signer fails
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewMultiSigner(asymmetric, nil)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorKeyAlgorithmMismatch+": 'ed25519, rsa-sha256'")
+
+	// an ed25519 key is a valid HMAC secret, so they can not be combined in any order
+	signer = httpsignatures.NewMultiSigner([]string{httpsignatures.AlgorithmEd25519,
+		httpsignatures.AlgorithmHmacSha256}, nil)
+	err = signer.SignRequest(r, testKeyID, ed25519TestPrivateKey)
+	assert.EqualError(t, err, httpsignatures.ErrorHMACWithEd25519)
+	signer = httpsignatures.NewMultiSigner([]string{httpsignatures.AlgorithmHmacSha256,
+		httpsignatures.AlgorithmEd25519}, nil)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorHMACWithEd25519)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+
+	// a raw HMAC secret is no base64 key for RSA, so hmac-sha256 is selected
+	signer = httpsignatures.NewMultiSigner(withHMAC, nil, httpsignatures.WithRawHMACKey())
+	err = signer.SignRequest(r, testKeyID, "a raw shared secret!")
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `algorithm="hmac-sha256"`)
+
+	signer = httpsignatures.NewMultiSigner([]string{httpsignatures.AlgorithmEd25519,
+		httpsignatures.AlgorithmRsaSha256}, nil)
+	err = signer.SignRequest(r, testKeyID, "not base64!")
+	assert.True(t, strings.HasPrefix(err.Error(), httpsignatures.ErrorInvalidKeyEncoding), err.Error())
+
+	signer = httpsignatures.NewMultiSigner([]string{httpsignatures.AlgorithmEd25519, "rot13"}, nil)
+	err = signer.SignRequest(r, testKeyID, ed25519TestPrivateKey)
+	assert.NotNil(t, err)
+}
+
+func TestSignWithCreated(t *testing.T) {
+	created := time.Date(2012, time.January, 5, 21, 31, 40, 0, time.UTC)
+	r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha256,
+		[]string{"(created)", "date"}, httpsignatures.WithCreated(created))
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, created.Unix(), s.Created)
+	assert.Equal(t, testDate, r.Header.Get("Date"))
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithDateCreatedConsistency(time.Second))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// Signature does not modify the request, but signs the Date of the created time
+	r, err = http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+	signature, err := signer.Signature(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Signature, signature)
+	assert.Empty(t, r.Header.Get("Date"))
+
+	// without a signed date the Date header is left alone
+	r, err = http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+	signer = httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha256,
+		[]string{"(created)"}, httpsignatures.WithCreated(created))
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Empty(t, r.Header.Get("Date"))
+}
+
+func TestSignedClone(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+
+	clone, err := DefaultSha256Signer.SignedClone(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, http.Header{"Date": []string{testDate}}, r.Header)
+	assert.Equal(t, `keyId="Test",algorithm="hmac-sha256",headers="date",signature="`+testSha256Hash+`"`,
+		clone.Header.Get("Signature"))
+
+	res, err := httpsignatures.VerifyRequest(clone, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// a retry is signed from the unchanged original
+	retry, err := DefaultSha256Signer.SignedClone(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, clone.Header, retry.Header)
+}
+
+func TestVerifyExpiresBeforeCreated(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	for _, expires := range []string{"1325799000", "1325799100"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("(created): 1325799100\n(expires): " + expires + "\ndate: " + testDate))
+		r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",created=1325799100,expires=`+expires+
+			`,headers="(created) (expires) date",signature="`+base64.StdEncoding.EncodeToString(mac.Sum(nil))+`"`)
+
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.False(t, res)
+		assert.EqualError(t, err, httpsignatures.ErrorInvalidExpiry)
+	}
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorInvalidExpiry)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+}
+
+func BenchmarkSignRequest(b *testing.B) {
+	r, _ := http.NewRequest(http.MethodPost, "https://example.com/foo?param=value", nil)
+	r.Header.Set("Date", testDate)
+	r.Header.Set("Content-Type", "application/json")
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date", "content-type")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Header.Del("Signature")
+		if err := signer.SignRequest(r, testKeyID, testKey); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSignAndVerifyStandardHeaders(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	r.Header.Set("User-Agent", "test-client/1.0")
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("Accept", "application/json")
+
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "User-Agent", "Referer", "ACCEPT", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `headers="(request-target) user-agent referer accept date"`)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		"User-Agent", "referer")
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Set("Referer", "https://evil.example.com/")
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestSignAndVerifyAbsentStandardHeaders(t *testing.T) {
+	for _, header := range []string{"User-Agent", "Referer", "Accept"} {
+		r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+		assert.Nil(t, err)
+		r.Header.Set("Date", testDate)
+
+		signer := httpsignatures.NewSigner("hmac-sha256", "date", header)
+		err = signer.SignRequest(r, testKeyID, testKey)
+		assert.EqualError(t, err, fmt.Sprintf("%s '%s'", httpsignatures.ErrorMissingRequiredHeader,
+			strings.ToLower(header)))
+
+		r.Header.Set(header, "value")
+		err = signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+		r.Header.Del(header)
+
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.False(t, res)
+		assert.EqualError(t, err, fmt.Sprintf("%s '%s'", httpsignatures.ErrorMissingRequiredHeader,
+			strings.ToLower(header)))
+	}
+}
+
+func TestVerifyImplicitXDate(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"X-Date": []string{testDate},
+		},
+	}
+	err := httpsignatures.NewSigner("hmac-sha256", "x-date").SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	// the client relies on the implicit header list
+	r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), `headers="x-date",`, "", 1))
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"x-date"}, s.HeaderList)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the date header is preferred when both are present
+	r.Header.Set("Date", testDate)
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"date"}, s.HeaderList)
+}
+
+func TestVerifyWithStrictParameters(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithStrictParameters())
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Set("Signature", r.Header.Get("Signature")+`,garbage="bob"`)
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithStrictParameters())
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorUnknownSignatureParameter+" 'garbage'")
+}
+
+func TestSignAndVerifyHttptestRequest(t *testing.T) {
+	headers := []string{"(request-target)", "host", "date"}
+	signer := httpsignatures.NewSigner("hmac-sha256", headers...)
+
+	r := httptest.NewRequest(http.MethodPost, "/foo?x=1", nil)
+	r.Header.Set("Date", testDate)
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "post /foo?x=1", s.Headers["(request-target)"])
+	assert.Equal(t, "example.com", s.Headers["host"])
+
+	// a client request for the same target verifies as the server request
+	client, err := http.NewRequest(http.MethodPost, "http://example.com/foo?x=1", nil)
+	assert.Nil(t, err)
+	client.Header.Set("Date", testDate)
+	err = signer.SignRequest(client, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, client.Header.Get("Signature"), r.Header.Get("Signature"))
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		headers...)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyQuotedCreated(t *testing.T) {
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("(created): 1609459200\ndate: " + testDate))
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+			"Signature": []string{`keyId="Test",algorithm="hmac-sha256",created="1609459200",` +
+				`headers="(created) date",signature="` + base64.StdEncoding.EncodeToString(mac.Sum(nil)) + `"`},
+		},
+	}
+
+	var s httpsignatures.SignatureParameters
+	err := s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1609459200), s.Created)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",created="yesterday",signature="abc"`)
+	err = s.FromRequest(r)
+	assert.EqualError(t, err, httpsignatures.ErrorMalformedSignatureHeader)
+}
+
+func TestSignAndVerifyBytesHmac(t *testing.T) {
+	data := []byte("date: " + testDate)
+	signature, err := httpsignatures.SignBytes(httpsignatures.AlgorithmHmacSha256, testKey, data)
+	assert.Nil(t, err)
+	assert.Equal(t, testSha256Hash, signature)
+
+	res, err := httpsignatures.VerifyBytes(httpsignatures.AlgorithmHmacSha256, testKey, signature, data)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyBytes(httpsignatures.AlgorithmHmacSha256, testKey, signature, []byte("other"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	_, err = httpsignatures.SignBytes("hmac-md5", testKey, data)
+	assert.NotNil(t, err)
+}
+
+func TestSignAndVerifyBytesRsa(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	pubKey := base64.StdEncoding.EncodeToString(der)
+
+	data := []byte(`{"event":"created","id":42}`)
+	signature, err := httpsignatures.SignBytes(httpsignatures.AlgorithmRsaSha256, privKey, data)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyBytes(httpsignatures.AlgorithmRsaSha256, pubKey, signature, data)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyBytes(httpsignatures.AlgorithmRsaSha256, pubKey, signature, data[1:])
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestVerifyLegacyFragmentInTarget(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo#bar", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	legacy := httpsignatures.WithLegacyFragmentInTarget()
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(request-target)", "date"}, legacy)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, legacy)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// by default the signature does not depend on the fragment
+	r.Header.Del("Signature")
+	err = httpsignatures.NewSigner("hmac-sha256", "(request-target)", "date").SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	r.URL.Fragment = ""
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyLooselyFormattedAuthorizationHeader(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+
+	// computed independently of this package, with
+	// printf '(request-target): get /foo\ndate: <testDate>' |
+	//   openssl dgst -sha256 -mac HMAC -macopt hexkey:<testKey as hex> -binary | base64
+	signature := "Uh8blOX3KXglICo1T36nLDVV5GCfLG1tFfydyejNKXU="
+
+	// a lower or upper case scheme, spaces after the commas and headers
+	// separated by more than one space are accepted
+	for _, authHeader := range []string{
+		`signature keyId="Test", algorithm="hmac-sha256", headers="(request-target)  date", signature="` +
+			signature + `"`,
+		`SIGNATURE	keyId="Test",algorithm="hmac-sha256",headers=" (request-target) date ",signature="` +
+			signature + `"`,
+	} {
+		r.Header.Set("Authorization", authHeader)
+		assert.Nil(t, httpsignatures.ValidateAuthorizationHeader(authHeader))
+
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}
+}
+
+func TestSignerSignature(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signature, err := DefaultSha256Signer.Signature(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Equal(t, testSha256Hash, signature)
+	assert.Empty(t, r.Header.Get("Signature"))
+
+	_, err = DefaultSha256Signer.Signature(r, "", testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorNoKeyIDConfigured)
+}
+
+func TestSignOptionalHeaders(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"date", "host", "x-request-id"},
+		httpsignatures.WithOptionalHeaders("X-Request-Id"))
+
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `headers="date host"`)
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Del("Signature")
+	r.Header.Set("X-Request-Id", "42")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `headers="date host x-request-id"`)
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// headers which are not optional are still required
+	r.Header.Del("Signature")
+	r.Header.Del("Date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" 'date'")
+}
+
+func TestVerifyRequiredPseudoHeaders(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, "https://example.com/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		httpsignatures.HeaderRequestTarget)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorRequiredHeaderNotInHeaderList+": '(request-target)'")
+
+	r.Header.Del("Signature")
+	r.Header.Set("X-Empty", "")
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "(created)", "date", "x-empty")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	// a signed header with an empty value is covered
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		httpsignatures.HeaderRequestTarget, httpsignatures.HeaderCreated, "X-Empty")
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyWithKeyIDFromHeader(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":     []string{testDate},
+			"X-Key-Id": []string{"client-42"},
+		},
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "x-key-id")
+	err := signer.SignRequest(r, "unused", testKey)
+	assert.Nil(t, err)
+	// the keyId is passed out of band only
+	r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), `keyId="unused",`, "", 1))
+
+	var lookedUp string
+	headerKeyLookUp := func(keyID string) (string, error) {
+		lookedUp = keyID
+		return testKey, nil
+	}
+	fromHeader := httpsignatures.WithKeyIDFromHeader("X-Key-Id")
+	res, err := httpsignatures.VerifyRequestWithOptions(r, headerKeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, fromHeader)
+	assert.True(t, res)
+	assert.Nil(t, err)
+	assert.Equal(t, "client-42", lookedUp)
+
+	res, err = httpsignatures.VerifyRequest(r, headerKeyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingSignatureParameterKeyId)
+
+	// the header must be bound by the signature
+	r.Header.Del("Signature")
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, headerKeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, fromHeader)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorKeyIDHeaderNotSigned+": 'X-Key-Id'")
+}
+
+func TestVerifyWithDateCreatedConsistency(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{time.Now().Format(time.RFC1123)},
+		},
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "(created)", "date")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	consistency := httpsignatures.WithDateCreatedConsistency(time.Minute)
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, consistency)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	r.Header.Del("Signature")
+	r.Header.Set("Date", testDate)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, consistency)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorDateCreatedMismatch)
+}
+
+func TestComputeSignatureGolden(t *testing.T) {
+	var params httpsignatures.SignatureParameters
+	err := params.FromConfig(testKeyID, httpsignatures.AlgorithmHmacSha256,
+		[]string{"(request-target)", "host", "date", "content-type", "digest"})
+	assert.Nil(t, err)
+	params.Headers = httpsignatures.HeaderValues{
+		"(request-target)": "post /foo?param=value&pet=dog",
+		"host":             "example.com",
+		"date":             testDate,
+		"content-type":     "application/json",
+		"digest":           "SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=",
+	}
+
+	signingString, header, err := httpsignatures.ComputeSignature(params, testKey)
+	assert.Nil(t, err)
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "compute_signature.golden"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(golden), signingString+"\n\n"+header+"\n")
+
+	delete(params.Headers, "digest")
+	_, _, err = httpsignatures.ComputeSignature(params, testKey)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingRequiredHeader+" 'digest'")
+}
+
+func TestSignAndVerifyIPv6Host(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://[::1]:8443/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "get /foo", s.Headers["(request-target)"])
+	assert.Equal(t, "[::1]:8443", s.Headers["host"])
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestHeaderValueWhitespace(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date":         []string{testDate},
+			"Content-Type": []string{" \tapplication/json \t"},
+		},
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date", "content-type")
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", s.Headers["content-type"])
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// a proxy normalizing the padding does not break the signature
+	r.Header.Set("Content-Type", "application/json")
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	preserve := httpsignatures.WithPreservedHeaderWhitespace()
+	r.Header.Del("Signature")
+	r.Header.Set("Content-Type", " \tapplication/json \t")
+	signer = httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"date", "content-type"}, preserve)
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, preserve)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestVerifyIPv6TrustedHost(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://[::1]:8080/foo", nil)
+	assert.Nil(t, err)
+	r.Header.Set("Date", testDate)
+	signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "host", "date")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	verify := func(r *http.Request, opts ...httpsignatures.Option) (bool, error) {
+		return httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1,
+			[]string{httpsignatures.AlgorithmHmacSha256}, []string{"host"}, opts...)
+	}
+
+	// the proxy forwards to an IPv6 backend and keeps the original host
+	r.Host = "[fd00::2]:9000"
+	r.Header.Set("X-Forwarded-Host", "[::1]:8080, [fd00::1]")
+	res, err := verify(r, httpsignatures.WithTrustedHostHeader("X-Forwarded-Host"))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// a host without brackets or with another port is a different host
+	for _, host := range []string{"::1:8080", "[::1]", "[::1]:8443"} {
+		r.Header.Set("X-Forwarded-Host", host)
+		res, err = verify(r, httpsignatures.WithTrustedHostHeader("X-Forwarded-Host"))
+		assert.False(t, res, host)
+		assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch, host)
+	}
+}
+
+func TestIncludeSigningStringInError(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	r.Header.Set("Date", "Thu, 05 Jan 2012 21:31:41 GMT")
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithIncludeSigningStringInError())
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch+
+		`, signing string "date: Thu, 05 Jan 2012 21:31:41 GMT"`)
+	code, msg := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, httpsignatures.ErrorSignaturesDoNotMatch, msg)
+}
+
+func TestVerifyWithKeyAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	pubKey := base64.StdEncoding.EncodeToString(der)
+
+	// the public key is known to anyone, so an attacker can use it as hmac secret
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = DefaultSha256Signer.SignRequest(r, "rsa-key", pubKey)
+	assert.Nil(t, err)
+
+	rsaKeyLookUp := func(keyID string) (string, error) {
+		return pubKey, nil
+	}
+	allowed := []string{httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHmacSha256}
+	res, err := httpsignatures.VerifyRequest(r, rsaKeyLookUp, -1, allowed)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowedForKey+": 'hmac-sha256'")
+
+	keyAlgorithm := httpsignatures.WithKeyAlgorithm(func(keyID string) (string, error) {
+		assert.Equal(t, "rsa-key", keyID)
+		return httpsignatures.AlgorithmRsaSha256, nil
+	})
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rsaKeyLookUp, -1, allowed, nil, keyAlgorithm)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowedForKey+": 'rsa-key'")
+
+	r.Header.Del("Signature")
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmRsaSha256)
+	err = signer.SignRequest(r, "rsa-key", base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key)))
+	assert.Nil(t, err)
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rsaKeyLookUp, -1, allowed, nil, keyAlgorithm)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestFromRequestVerifyWith(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, testKeyID, s.KeyID)
+
+	key, err := keyLookUp(s.KeyID)
+	assert.Nil(t, err)
+	res, err := s.VerifyWith(key, r)
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the header values are taken from the request
+	r.Header.Set("Date", "Thu, 05 Jan 2012 21:31:41 GMT")
+	res, err = s.VerifyWith(key, r)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+
+	res, err = httpsignatures.SignatureParameters{}.VerifyWith(key, r)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorMissingSignatureParameterAlgorithm)
+}
+
+func TestVerifyWithClockSkewDuration(t *testing.T) {
+	now, err := time.Parse(http.TimeFormat, testDate)
+	assert.Nil(t, err)
+	clock := httpsignatures.WithClock(func() time.Time { return now })
+
+	verify := func(date time.Time, opt httpsignatures.Option) (bool, error) {
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{date.UTC().Format(http.TimeFormat)},
+			},
+		}
+		err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+		// the allowedClockSkew argument is overridden by the option
+		return httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 0, []string{httpsignatures.AlgorithmHmacSha256},
+			nil, opt, clock)
+	}
+
+	// no tolerance
+	res, err := verify(now, httpsignatures.WithClockSkew(0))
+	assert.True(t, res)
+	assert.Nil(t, err)
+	for _, offset := range []time.Duration{-time.Second, time.Second} {
+		res, err = verify(now.Add(offset), httpsignatures.WithClockSkew(0))
+		assert.False(t, res)
+		assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+	}
+
+	// tolerance in both directions
+	for _, offset := range []time.Duration{-time.Minute, time.Minute} {
+		res, err = verify(now.Add(offset), httpsignatures.WithClockSkew(time.Minute))
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}
+	for _, offset := range []time.Duration{-time.Minute - time.Second, time.Minute + time.Second} {
+		res, err = verify(now.Add(offset), httpsignatures.WithClockSkew(time.Minute))
+		assert.False(t, res)
+		assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+	}
+
+	// disabled
+	res, err = verify(now.Add(-24*time.Hour), httpsignatures.WithoutClockSkewCheck())
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestVerifyWithClock(t *testing.T) {
+	now, err := time.Parse(http.TimeFormat, testDate)
+	assert.Nil(t, err)
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	verify := func(after time.Duration) (bool, error) {
+		return httpsignatures.VerifyRequestWithOptions(r, keyLookUp, 300, []string{httpsignatures.AlgorithmHmacSha256},
+			nil, clockAfterTestDate(after))
+	}
+	res, err := verify(300 * time.Second)
+	assert.True(t, res)
+	assert.Nil(t, err)
+	res, err = verify(301 * time.Second)
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAllowedClockskewExceeded)
+
+	// the signer takes the created and expires parameters from the clock
+	clock := httpsignatures.WithClock(func() time.Time { return now })
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(created)"}, clock,
+		httpsignatures.WithExpiry(time.Minute))
+	r = &http.Request{Header: http.Header{}}
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Unix(), s.Created)
+	assert.Equal(t, now.Add(time.Minute).Unix(), s.Expires)
+
+	for at, expected := range map[time.Duration]string{
+		time.Minute:               "",
+		time.Minute + time.Second: httpsignatures.ErrorSignatureExpired,
+	} {
+		res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1,
+			[]string{httpsignatures.AlgorithmHmacSha256}, nil, clockAfterTestDate(at))
+		if expected == "" {
+			assert.True(t, res)
+			assert.Nil(t, err)
+		} else {
+			assert.False(t, res)
+			assert.EqualError(t, err, expected)
+		}
+	}
+}
+
+func TestSignRequestWith(t *testing.T) {
+	newRequest := func() *http.Request {
+		return &http.Request{
+			Header: http.Header{
+				"Date":    []string{testDate},
+				"X-Extra": []string{"extra"},
+			},
+		}
+	}
+	signer := httpsignatures.NewSigner("hmac-sha256", "date")
+
+	r := newRequest()
+	err := signer.SignRequestWith(r, testKeyID, testKey, []string{"date", "X-Extra"})
+	assert.Nil(t, err)
+	var s httpsignatures.SignatureParameters
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"date", "x-extra"}, s.HeaderList)
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256}, "x-extra")
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the signer keeps its own header list
+	r = newRequest()
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	err = s.FromRequest(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"date"}, s.HeaderList)
+	assert.Equal(t, testSha256Hash, s.Signature)
+
+	err = signer.SignRequestWith(newRequest(), testKeyID, testKey, []string{"date", "date"})
+	assert.EqualError(t, err, httpsignatures.ErrorDuplicateHeader+" 'date'")
+}
+
+func TestVerifySignatureHeaderWithKeyIDHeader(t *testing.T) {
+	for _, name := range []string{"Signature", "X-Gateway-Signature"} {
+		signatureHeader := httpsignatures.WithSignatureHeaderName(name)
+		keyIDHeader := httpsignatures.WithKeyIDFromHeader("X-Key-Id")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headerKeyLookUp := func(keyID string) (string, error) {
+				assert.Equal(t, testKeyID, keyID)
+				return testKey, nil
+			}
+			res, err := httpsignatures.VerifyRequestWithOptions(r, headerKeyLookUp, -1,
+				[]string{httpsignatures.AlgorithmHmacSha256}, nil, signatureHeader, keyIDHeader)
+			if !res {
+				httpErr, msg := httpsignatures.ErrorToHTTPCode(err.Error())
+				http.Error(w, msg, httpErr)
+			}
+		}))
+
+		r, err := http.NewRequest(http.MethodGet, server.URL+"/foo", nil)
+		assert.Nil(t, err)
+		r.Header.Set("Date", testDate)
+		r.Header.Set("X-Key-Id", testKeyID)
+		signer := httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(request-target)", "date", "x-key-id"},
+			signatureHeader)
+		err = signer.SignRequest(r, "ignored", testKey)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, r.Header.Get(name), name)
+		assert.Empty(t, r.Header.Get("Authorization"), name)
+
+		res, err := http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode, name)
+
+		// the keyId header must be signed
+		r.Header.Del(name)
+		signer = httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(request-target)", "date"},
+			signatureHeader)
+		err = signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+		res, err = http.DefaultClient.Do(r)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, name)
+
+		server.Close()
+	}
+
+	// without a signature in the named header the verification fails
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+		nil, httpsignatures.WithSignatureHeaderName("X-Gateway-Signature"))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorNoSignatureHeaderFoundInRequest+": 'X-Gateway-Signature'")
+}
+
+func TestSignConcurrently(t *testing.T) {
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256",
+		[]string{"(request-target)", "host", "date", "(created)", "x-request-id"},
+		httpsignatures.WithOptionalHeaders("x-request-id"), httpsignatures.WithExpiry(time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://example.com/foo/%d", i), nil)
+			assert.Nil(t, err)
+			r.Header.Set("Date", testDate)
+			if i%2 == 0 {
+				r.Header.Set("X-Request-Id", fmt.Sprint(i))
+			}
+			if i%3 == 0 {
+				err = signer.AuthRequest(r, testKeyID, testKey)
+			} else {
+				err = signer.SignRequest(r, testKeyID, testKey)
+			}
+			assert.Nil(t, err)
+
+			res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256},
+				"(request-target)")
+			assert.True(t, res)
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestVerifyWithKeyAlgorithmMap(t *testing.T) {
+	keyAlgorithms := httpsignatures.WithKeyAlgorithmMap(map[string]string{
+		"hmac-key": httpsignatures.AlgorithmHmacSha256,
+		"rsa-key":  httpsignatures.AlgorithmRsaSha256,
+	})
+	allowed := []string{httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHmacSha256}
+
+	for keyID, expected := range map[string]string{
+		"hmac-key":    "",
+		"rsa-key":     httpsignatures.ErrorAlgorithmNotAllowedForKey + ": 'rsa-key'",
+		"unknown-key": httpsignatures.ErrorAlgorithmNotAllowedForKey + ": 'unknown-key'",
+	} {
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{testDate},
+			},
+		}
+		err := DefaultSha256Signer.SignRequest(r, keyID, testKey)
+		assert.Nil(t, err)
+
+		res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, allowed, nil, keyAlgorithms)
+		if expected == "" {
+			assert.True(t, res, keyID)
+			assert.Nil(t, err, keyID)
+		} else {
+			assert.False(t, res, keyID)
+			assert.EqualError(t, err, expected, keyID)
+		}
+	}
+}
+
+func TestVerifyBase64URLSignature(t *testing.T) {
+	urlSafe := strings.NewReplacer("+", "-", "/", "_").Replace(testSha1Hash)
+	assert.NotEqual(t, testSha1Hash, urlSafe)
+
+	for _, signature := range []string{testSha1Hash, urlSafe, strings.TrimRight(urlSafe, "=")} {
+		r := &http.Request{
+			Header: http.Header{
+				"Date":      []string{testDate},
+				"Signature": []string{`keyId="Test",algorithm="hmac-sha1",signature="` + signature + `"`},
+			},
+		}
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha1})
+		assert.True(t, res, signature)
+		assert.Nil(t, err, signature)
+	}
+
+	// the signer emits standard base64
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha1Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), `signature="`+testSha1Hash+`"`)
+}
+
+func TestVerifyUsesHeaderListOrder(t *testing.T) {
+	key, _ := base64.StdEncoding.DecodeString(testKey)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("x-b: 2\ndate: " + testDate + "\nx-a: 1"))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// the request headers are in another order than the signed headers
+	r := &http.Request{Header: http.Header{}}
+	r.Header.Add("X-A", "1")
+	r.Header.Add("Date", testDate)
+	r.Header.Add("X-B", "2")
+	r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",headers="x-b date x-a",signature="`+
+		signature+`"`)
+
+	res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	// the same headers in another order are another signature
+	r.Header.Set("Signature", `keyId="Test",algorithm="hmac-sha256",headers="date x-a x-b",signature="`+
+		signature+`"`)
+	res, err = httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch)
+}
+
+func TestSignAndVerifyRawHMACKey(t *testing.T) {
+	secret := "a raw shared secret"
+	rawKey := httpsignatures.WithRawHMACKey()
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+
+	signer := httpsignatures.NewSignerWithOptions(httpsignatures.AlgorithmHmacSha256, nil, rawKey)
+	err := signer.SignRequest(r, testKeyID, secret)
+	assert.Nil(t, err)
+
+	// the raw secret signs like its base64 encoding does by default
+	expected := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = DefaultSha256Signer.SignRequest(expected, testKeyID, base64.StdEncoding.EncodeToString([]byte(secret)))
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Header.Get("Signature"), r.Header.Get("Signature"))
+
+	rawKeyLookUp := func(keyID string) (string, error) {
+		return secret, nil
+	}
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rawKeyLookUp, -1,
+		[]string{httpsignatures.AlgorithmHmacSha256}, nil, rawKey)
+	assert.True(t, res)
+	assert.Nil(t, err)
+}
+
+func TestSignWithInvalidKeyEncoding(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha256Signer.SignRequest(r, testKeyID, "not base64!")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), httpsignatures.ErrorInvalidKeyEncoding))
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusInternalServerError, httpErr)
+}
+
+func TestVerifyHMACWithRSAPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	publicKey := base64.StdEncoding.EncodeToString(der)
+
+	// the attacker signs with HMAC, using the public RSA key as the secret
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err = DefaultSha256Signer.SignRequest(r, testKeyID, publicKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequest(r, func(keyID string) (string, error) {
+		return publicKey, nil
+	}, -1, []string{httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHmacSha256})
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorAlgorithmNotAllowedForKey+": 'hmac-sha256'")
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+}
+
+func TestSignWithMismatchedKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	rsaKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+
+	for _, test := range []struct {
+		algorithm string
+		key       string
+	}{
+		{httpsignatures.AlgorithmRsaSha256, testKey},
+		{httpsignatures.AlgorithmRsaPssSha512, ed25519TestPrivateKey},
+		{httpsignatures.AlgorithmEd25519, testKey},
+		{httpsignatures.AlgorithmEd25519, rsaKey},
+		{httpsignatures.AlgorithmHmacSha256, rsaKey},
+	} {
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{testDate},
+			},
+		}
+		err := httpsignatures.NewSigner(test.algorithm).SignRequest(r, testKeyID, test.key)
+		assert.EqualError(t, err, httpsignatures.ErrorKeyAlgorithmMismatch+": '"+test.algorithm+"'")
+		httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+		assert.Equal(t, http.StatusInternalServerError, httpErr)
+	}
+}
+
+func TestRequestTargetEncodedPath(t *testing.T) {
+	for _, test := range []struct {
+		url     string
+		target  string
+		decoded string
+	}{
+		{"https://example.com/foo%2Fbar", "get /foo%2Fbar", "get /foo/bar"},
+		{"https://example.com/caf%C3%A9/a%20b?q=%2F", "get /caf%C3%A9/a%20b?q=%2F", "get /café/a b?q=%2F"},
+		{"https://example.com/foo/", "get /foo/", "get /foo/"},
+	} {
+		r, err := http.NewRequest(http.MethodGet, test.url, nil)
+		assert.Nil(t, err)
+		r.Header.Set("Date", testDate)
+		signer := httpsignatures.NewSigner("hmac-sha256", "(request-target)", "date")
+		err = signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+
+		var s httpsignatures.SignatureParameters
+		err = s.FromRequest(r)
+		assert.Nil(t, err)
+		assert.Equal(t, test.target, s.Headers["(request-target)"])
+
+		// a server receives the escaped path
+		server := httptest.NewRequest(http.MethodGet, test.url, nil)
+		server.Header = r.Header
+		res, err := httpsignatures.VerifyRequest(server, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.True(t, res, test.url)
+		assert.Nil(t, err, test.url)
+
+		// the signer and verification must agree on decoding
+		decoded := httpsignatures.WithDecodedPath()
+		res, err = httpsignatures.VerifyRequestWithOptions(server, keyLookUp, -1,
+			[]string{httpsignatures.AlgorithmHmacSha256}, nil, decoded)
+		assert.Equal(t, test.target == test.decoded, res, test.url)
+		if test.target != test.decoded {
+			assert.EqualError(t, err, httpsignatures.ErrorSignaturesDoNotMatch, test.url)
+		}
+
+		r.Header.Del("Signature")
+		signer = httpsignatures.NewSignerWithOptions("hmac-sha256", []string{"(request-target)", "date"}, decoded)
+		err = signer.SignRequest(r, testKeyID, testKey)
+		assert.Nil(t, err)
+		res, err = httpsignatures.VerifyRequestWithOptions(server, keyLookUp, -1,
+			[]string{httpsignatures.AlgorithmHmacSha256}, nil, decoded)
+		assert.True(t, res, test.url)
+		assert.Nil(t, err, test.url)
+	}
+}
+
+func TestSignWithKeySource(t *testing.T) {
+	otherKey := base64.StdEncoding.EncodeToString([]byte("rotated key"))
+	keys := map[string]string{testKeyID: testKey, "rotated": otherKey}
+	current := testKeyID
+	var sourceErr error
+	source := func() (string, string, error) {
+		return current, keys[current], sourceErr
+	}
+	signer := httpsignatures.NewSignerWithOptions("hmac-sha256", nil, httpsignatures.WithKeySource(source))
+	rotatingKeyLookUp := func(keyID string) (string, error) {
+		return keys[keyID], nil
+	}
+
+	for _, keyID := range []string{testKeyID, "rotated"} {
+		current = keyID
+		r := &http.Request{
+			Header: http.Header{
+				"Date": []string{testDate},
+			},
+		}
+		err := signer.SignRequest(r, "", "")
+		assert.Nil(t, err)
+
+		var s httpsignatures.SignatureParameters
+		err = s.FromRequest(r)
+		assert.Nil(t, err)
+		assert.Equal(t, keyID, s.KeyID)
+		res, err := httpsignatures.VerifyRequest(r, rotatingKeyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.True(t, res)
+		assert.Nil(t, err)
+	}
+
+	// an explicit key is used as is
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+	assert.Contains(t, r.Header.Get("Signature"), testSha256Hash)
+
+	sourceErr = errors.New("secrets manager unavailable")
+	err = signer.SignRequest(&http.Request{Header: http.Header{}}, "", "")
+	assert.EqualError(t, err, "secrets manager unavailable")
+}
+
+func TestVerifyIgnoresUnsignedDate(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	assert.Nil(t, err)
+
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmHmacSha256, "(request-target)", "host")
+	err = signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	for _, date := range []string{testDate, "not a date"} {
+		r.Header.Set("Date", date)
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha256})
+		assert.True(t, res, date)
+		assert.Nil(t, err, date)
+	}
+}
+
+func TestVerifyClientAlgorithmErrorsAreBadRequests(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	for _, algorithm := range []string{"foo", httpsignatures.AlgorithmRsaSha256, httpsignatures.AlgorithmHs2019} {
+		r.Header.Set("Signature", `keyId="Test",algorithm="`+algorithm+`",signature="`+testSha256Hash+`"`)
+		res, err := httpsignatures.VerifyRequest(r, keyLookUp, -1, []string{algorithm})
+		assert.False(t, res, algorithm)
+		if assert.NotNil(t, err, algorithm) {
+			httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+			assert.Equal(t, http.StatusBadRequest, httpErr, err.Error())
+		}
+	}
+}
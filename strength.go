@@ -0,0 +1,86 @@
+package httpsignatures
+
+import (
+	"errors"
+	"strings"
+)
+
+// SignatureStrength estimates the security strength in bits of a signature
+// made with the algorithm and a key of keyBits, after NIST SP 800-57. The
+// score is the weakest of the hash function and the key; SHA-1 counts as 80
+// bits. Registered HMAC algorithms count half the bits of their hash, like
+// the built-in ones. The algorithm name is case insensitive, unknown
+// algorithms score 0.
+func SignatureStrength(algorithm string, keyBits int) int {
+	var hashStrength, keyStrength int
+	switch algorithm = strings.ToLower(algorithm); algorithm {
+	case AlgorithmHmacSha1:
+		hashStrength, keyStrength = 80, keyBits
+	case AlgorithmHmacSha256:
+		hashStrength, keyStrength = 128, keyBits
+	case AlgorithmEd25519:
+		hashStrength, keyStrength = 128, 128
+	case AlgorithmRsaSha256:
+		hashStrength, keyStrength = 128, rsaStrength(keyBits)
+	case AlgorithmRsaPssSha512:
+		hashStrength, keyStrength = 256, rsaStrength(keyBits)
+	default:
+		if alg, size, ok := registeredAlgorithm(algorithm); ok && alg.Kind == AlgorithmKindHMAC {
+			hashStrength, keyStrength = size*8/2, keyBits
+		}
+	}
+
+	if keyStrength < hashStrength {
+		return keyStrength
+	}
+	return hashStrength
+}
+
+func rsaStrength(keyBits int) int {
+	switch {
+	case keyBits < 1024:
+		return 0
+	case keyBits < 2048:
+		return 80
+	case keyBits < 3072:
+		return 112
+	case keyBits < 7680:
+		return 128
+	case keyBits < 15360:
+		return 192
+	}
+	return 256
+}
+
+// keyBits returns the size of the base64 encoded key in bits as used by SignatureStrength
+func keyBits(alg *Algorithm, keyBase64 string, o options) (int, error) {
+	byteKey, err := o.decodeKey(alg, keyBase64)
+	if err != nil {
+		return 0, err
+	}
+	switch alg.Kind {
+	case AlgorithmKindRSAPKCS1v15, AlgorithmKindRSAPSS:
+		if key, err := parseRSAPublicKey(byteKey); err == nil {
+			return key.N.BitLen(), nil
+		}
+		key, err := parseRSAPrivateKey(byteKey)
+		if err != nil {
+			return 0, err
+		}
+		return key.N.BitLen(), nil
+	case AlgorithmKindEd25519:
+		return 256, nil
+	}
+	return len(byteKey) * 8, nil
+}
+
+func checkStrength(sig SignatureParameters, keyBase64 string, o options) error {
+	bits, err := keyBits(sig.Algorithm, keyBase64, o)
+	if err != nil {
+		return err
+	}
+	if SignatureStrength(sig.Algorithm.Name, bits) < o.minStrength {
+		return errors.New(ErrorSignatureTooWeak)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package httpsignatures_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+
+	"github.com/quantoztechnology/go-http-signatures"
+)
+
+func TestSignatureStrength(t *testing.T) {
+	assert.Equal(t, 80, httpsignatures.SignatureStrength(httpsignatures.AlgorithmRsaSha256, 1024))
+	assert.Equal(t, 112, httpsignatures.SignatureStrength(httpsignatures.AlgorithmRsaSha256, 2048))
+	assert.Equal(t, 128, httpsignatures.SignatureStrength(httpsignatures.AlgorithmRsaSha256, 4096))
+	assert.Equal(t, 192, httpsignatures.SignatureStrength(httpsignatures.AlgorithmRsaPssSha512, 8192))
+	assert.Equal(t, 128, httpsignatures.SignatureStrength(httpsignatures.AlgorithmEd25519, 256))
+	assert.Equal(t, 80, httpsignatures.SignatureStrength(httpsignatures.AlgorithmHmacSha1, 256))
+	assert.Equal(t, 64, httpsignatures.SignatureStrength(httpsignatures.AlgorithmHmacSha256, 64))
+	assert.Equal(t, 128, httpsignatures.SignatureStrength(httpsignatures.AlgorithmHmacSha256, 256))
+	assert.Equal(t, 0, httpsignatures.SignatureStrength("unknown", 4096))
+
+	// names are case insensitive
+	assert.Equal(t, 128, httpsignatures.SignatureStrength("HMAC-SHA256", 256))
+	assert.Equal(t, 112, httpsignatures.SignatureStrength("RSA-SHA256", 2048))
+}
+
+func TestSignatureStrengthRegisteredAlgorithm(t *testing.T) {
+	err := httpsignatures.RegisterAlgorithm("hmac-sha512", sha512.New, httpsignatures.AlgorithmKindHMAC)
+	assert.Nil(t, err)
+	err = httpsignatures.RegisterAlgorithm("hmac-sha224", sha256.New224, httpsignatures.AlgorithmKindHMAC)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 256, httpsignatures.SignatureStrength("hmac-sha512", 512))
+	assert.Equal(t, 128, httpsignatures.SignatureStrength("HMAC-SHA512", 128))
+	assert.Equal(t, 112, httpsignatures.SignatureStrength("hmac-sha224", 256))
+}
+
+func TestVerifyWithMinStrengthRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	privKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(key))
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	rsaKeyLookUp := func(keyID string) (string, error) {
+		return base64.StdEncoding.EncodeToString(der), nil
+	}
+
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	signer := httpsignatures.NewSigner(httpsignatures.AlgorithmRsaSha256)
+	err = signer.SignRequest(r, testKeyID, privKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmRsaSha256},
+		nil, httpsignatures.WithMinStrength(112))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, rsaKeyLookUp, -1, []string{httpsignatures.AlgorithmRsaSha256},
+		nil, httpsignatures.WithMinStrength(113))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignatureTooWeak)
+	httpErr, _ := httpsignatures.ErrorToHTTPCode(err.Error())
+	assert.Equal(t, http.StatusBadRequest, httpErr)
+}
+
+func TestVerifyWithMinStrengthHmacSha1(t *testing.T) {
+	r := &http.Request{
+		Header: http.Header{
+			"Date": []string{testDate},
+		},
+	}
+	err := DefaultSha1Signer.SignRequest(r, testKeyID, testKey)
+	assert.Nil(t, err)
+
+	res, err := httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha1},
+		nil, httpsignatures.WithMinStrength(80))
+	assert.True(t, res)
+	assert.Nil(t, err)
+
+	res, err = httpsignatures.VerifyRequestWithOptions(r, keyLookUp, -1, []string{httpsignatures.AlgorithmHmacSha1},
+		nil, httpsignatures.WithMinStrength(112))
+	assert.False(t, res)
+	assert.EqualError(t, err, httpsignatures.ErrorSignatureTooWeak)
+}